@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+// DefaultMaxBatchSize is the most URLs a single batch request may contain unless MAX_BATCH_SIZE overrides it.
+const DefaultMaxBatchSize = 100
+
+// BatchResult is the outcome for one entry of a batch request. Exactly one of ShortURL (with
+// its ShortKey) and Error is set.
+type BatchResult struct {
+	LongURL  string `json:"long_url"`
+	ShortURL string `json:"short_url,omitempty"`
+	ShortKey string `json:"short_key,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BatchShortenResponse struct {
+	Results   []BatchResult `json:"results"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+}
+
+// handleBatchShorten shortens a JSON array of shorten requests in one call.
+//
+// Entries are processed independently, so one bad URL doesn't fail the whole batch. The
+// response is 201 when every entry succeeded and 207 Multi-Status when some failed, with
+// per-entry results in the same order as the request. When creation is rate limited, the
+// batch spends one token per entry, so batching doesn't multiply a client's limit.
+func (s *Store) handleBatchShorten(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Bound the body so an oversized batch is rejected before it is fully decoded
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.maxBatchSize)*int64(shortener.MaxURLLength+64))
+
+	var reqs []ShortenRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON format, expected an array of shorten requests"})
+		return
+	}
+
+	if len(reqs) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "batch must contain at least one URL"})
+		return
+	}
+	if len(reqs) > s.maxBatchSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "batch exceeds the maximum size"})
+		return
+	}
+
+	// Every entry can create a link, so a batch spends one rate limit token per entry
+	if s.shortenLimiter != nil {
+		if ok, wait := s.shortenLimiter.allowN(s.clientIP(r), len(reqs)); !ok {
+			if wait == 0 {
+				writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+					Error: fmt.Sprintf("batch exceeds the rate limit burst of %d", s.shortenLimiter.burst)})
+				return
+			}
+			writeRateLimited(w, wait)
+			return
+		}
+	}
+
+	resp := BatchShortenResponse{Results: make([]BatchResult, len(reqs))}
+	for i, req := range reqs {
+		result := BatchResult{LongURL: req.LongURL}
+
+		if req.LongURL == "" {
+			result.Error = "long_url field is required"
+		} else if link, err := shortener.HandleShortURLRequest(r.Context(), req.LongURL, s.repo, req.linkOptions(r.Context())); err != nil {
+			_, result.Error = shortenErrorStatus(err)
+		} else {
+			result.ShortURL, result.ShortKey = link.ShortURL, link.ShortKey
+		}
+
+		if result.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+		resp.Results[i] = result
+	}
+
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, status, resp)
+}
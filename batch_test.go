@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postBatch sends a batch of n distinct URLs to handleBatchShorten from remoteAddr.
+func postBatch(s *Store, n int, remoteAddr string) *httptest.ResponseRecorder {
+	entries := make([]string, n)
+	for i := range entries {
+		entries[i] = fmt.Sprintf(`{"long_url": "https://example.com/%s/%d"}`, remoteAddr, i)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/shorten/batch",
+		bytes.NewBufferString("["+strings.Join(entries, ",")+"]"))
+	r.RemoteAddr = remoteAddr + ":1234"
+	w := httptest.NewRecorder()
+	s.handleBatchShorten(w, r)
+	return w
+}
+
+func TestBatchSpendsATokenPerEntry(t *testing.T) {
+	s, _ := newTestStore()
+	s.maxBatchSize = DefaultMaxBatchSize
+	s.shortenLimiter = newRateLimiter(0.001, 5)
+
+	if w := postBatch(s, 3, "192.0.2.1"); w.Code != http.StatusCreated {
+		t.Fatalf("batch of 3 = %d %s, want 201", w.Code, w.Body.String())
+	}
+	w := postBatch(s, 3, "192.0.2.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("batch of 3 with 2 tokens left = %d, want 429 with Retry-After", w.Code)
+	}
+	if w := postBatch(s, 2, "192.0.2.1"); w.Code != http.StatusCreated {
+		t.Errorf("batch of 2 with 2 tokens left = %d, want 201", w.Code)
+	}
+
+	// Waiting can't help a batch bigger than the whole burst
+	w = postBatch(s, 6, "192.0.2.2")
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "burst of 5") {
+		t.Errorf("batch larger than the burst = %d %s, want 413 naming the burst", w.Code, w.Body.String())
+	}
+}
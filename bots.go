@@ -0,0 +1,50 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// botInfoPage is served to bots instead of a redirect when bot info pages are enabled, so
+// crawlers see where a link leads without traversing it.
+var botInfoPage = template.Must(template.New("bot").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Short link</title></head>
+<body><p>This short link points to <a href="{{.}}" rel="nofollow">{{.}}</a></p></body>
+</html>
+`))
+
+// parseBotUserAgents splits a comma-separated list of User-Agent substrings, lowercasing
+// them for case-insensitive matching.
+func parseBotUserAgents(list string) []string {
+	var agents []string
+	for _, agent := range strings.Split(list, ",") {
+		agent = strings.ToLower(strings.TrimSpace(agent))
+		if agent != "" {
+			agents = append(agents, agent)
+		}
+	}
+	return agents
+}
+
+// isBot reports whether userAgent contains one of the configured bot substrings.
+func (s *Store) isBot(userAgent string) bool {
+	if len(s.botUserAgents) == 0 {
+		return false
+	}
+	userAgent = strings.ToLower(userAgent)
+	for _, agent := range s.botUserAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveBotInfoPage responds with a small HTML page linking to longURL instead of redirecting.
+func serveBotInfoPage(w http.ResponseWriter, longURL string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	botInfoPage.Execute(w, longURL)
+}
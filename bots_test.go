@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+const browserUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func TestBotRedirectsDontCountClicks(t *testing.T) {
+	s, repo := newTestStore()
+	s.botUserAgents = parseBotUserAgents("Googlebot, Slackbot")
+	key := createLink(t, repo, "https://example.com/", shortener.LinkOptions{})
+
+	w := followLink(s, http.MethodGet, "/"+key, "Mozilla/5.0 (compatible; Googlebot/2.1)")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/" {
+		t.Errorf("bot redirect = %d to %q, want 302 to the destination", w.Code, w.Header().Get("Location"))
+	}
+	if clicks, _ := repo.ClickCount(key); clicks != 1 {
+		t.Errorf("click count after a bot = %d, want 1 (unchanged)", clicks)
+	}
+
+	followLink(s, http.MethodGet, "/"+key, browserUserAgent)
+	if clicks, _ := repo.ClickCount(key); clicks != 2 {
+		t.Errorf("click count after a browser = %d, want 2", clicks)
+	}
+}
+
+func TestBotInfoPage(t *testing.T) {
+	s, repo := newTestStore()
+	s.botUserAgents = parseBotUserAgents("slackbot")
+	s.botInfoPage = true
+	key := createLink(t, repo, "https://example.com/?a=1&b=<2>", shortener.LinkOptions{})
+
+	w := followLink(s, http.MethodGet, "/"+key, "Slackbot-LinkExpanding 1.0")
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+		t.Errorf("bot info page = %d with Location %q, want 200 without a redirect", w.Code, w.Header().Get("Location"))
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `href="https://example.com/?a=1&amp;b=`) || strings.Contains(body, "<2>") {
+		t.Errorf("bot info page doesn't link to the escaped destination:\n%s", body)
+	}
+
+	if w := followLink(s, http.MethodGet, "/"+key, browserUserAgent); w.Code != http.StatusFound {
+		t.Errorf("browser status = %d, want a 302 redirect", w.Code)
+	}
+}
+
+func TestParseBotUserAgents(t *testing.T) {
+	got := parseBotUserAgents(" Googlebot ,,bingbot, ")
+	if len(got) != 2 || got[0] != "googlebot" || got[1] != "bingbot" {
+		t.Errorf("parseBotUserAgents = %q, want [googlebot bingbot]", got)
+	}
+}
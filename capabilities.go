@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+// CapabilitiesResponse lets clients feature-detect what this instance supports instead of
+// discovering it by trial and error.
+type CapabilitiesResponse struct {
+	Features CapabilityFeatures `json:"features"`
+	Limits   CapabilityLimits   `json:"limits"`
+}
+
+type CapabilityFeatures struct {
+	BatchShorten   bool   `json:"batch_shorten"`
+	KeyPreview     bool   `json:"key_preview"`
+	KeyStrategy    string `json:"key_strategy"`
+	Deduplication  bool   `json:"deduplication"`
+	ApprovalNeeded bool   `json:"approval_required"`
+	DailyClicks    bool   `json:"daily_clicks"`
+	CustomAliases  bool   `json:"custom_aliases"`
+	RedirectStatus int    `json:"redirect_status"`
+	APIKeyRequired bool   `json:"api_key_required"`
+}
+
+type CapabilityLimits struct {
+	MaxURLLength   int      `json:"max_url_length"`
+	MaxBatchSize   int      `json:"max_batch_size"`
+	KeyLength      int      `json:"key_length"`
+	AllowedSchemes []string `json:"allowed_schemes"`
+	// RateLimitRPS is the per-client link creation rate, omitted when unlimited
+	RateLimitRPS   float64 `json:"rate_limit_rps,omitempty"`
+	RateLimitBurst int     `json:"rate_limit_burst,omitempty"`
+}
+
+// handleCapabilities reports the features and limits of this instance's configuration.
+func (s *Store) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, hashKeys := shortener.Strategy.(shortener.HashStrategy)
+	resp := CapabilitiesResponse{
+		Features: CapabilityFeatures{
+			BatchShorten:   true,
+			KeyPreview:     hashKeys,
+			KeyStrategy:    shortener.Strategy.Name(),
+			Deduplication:  !shortener.Strategy.Unique(),
+			ApprovalNeeded: shortener.RequireApproval,
+			DailyClicks:    shortener.ClickTracking == shortener.ClickTrackingDaily,
+			CustomAliases:  false,
+			RedirectStatus: s.redirectStatus,
+			APIKeyRequired: s.apiKeysEnabled(),
+		},
+		Limits: CapabilityLimits{
+			MaxURLLength:   shortener.MaxURLLength,
+			MaxBatchSize:   s.maxBatchSize,
+			KeyLength:      shortener.KeyLength,
+			AllowedSchemes: []string{"http", "https"},
+		},
+	}
+	if s.shortenLimiter != nil {
+		resp.Limits.RateLimitRPS = s.shortenLimiter.rate
+		resp.Limits.RateLimitBurst = s.shortenLimiter.burst
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
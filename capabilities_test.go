@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+// getCapabilities returns the decoded capabilities response and its raw body.
+func getCapabilities(t *testing.T, s *Store) (CapabilitiesResponse, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.handleCapabilities(w, httptest.NewRequest(http.MethodGet, "/api/v1/capabilities", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp CapabilitiesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return resp, w.Body.String()
+}
+
+func TestCapabilitiesReportDisabledFeatures(t *testing.T) {
+	s, _ := newTestStore()
+	s.maxBatchSize = 50
+
+	resp, body := getCapabilities(t, s)
+	want := CapabilityFeatures{
+		BatchShorten:   true,
+		KeyPreview:     true,
+		KeyStrategy:    "hash",
+		Deduplication:  true,
+		RedirectStatus: http.StatusFound,
+	}
+	if resp.Features != want {
+		t.Errorf("features = %+v, want %+v", resp.Features, want)
+	}
+	if resp.Limits.MaxURLLength != shortener.MaxURLLength || resp.Limits.MaxBatchSize != 50 || resp.Limits.KeyLength != shortener.KeyLength {
+		t.Errorf("limits = %+v", resp.Limits)
+	}
+	// Disabled features are reported as false rather than left out
+	for _, field := range []string{`"approval_required":false`, `"daily_clicks":false`, `"custom_aliases":false`, `"api_key_required":false`} {
+		if !strings.Contains(body, field) {
+			t.Errorf("response is missing %s:\n%s", field, body)
+		}
+	}
+	if strings.Contains(body, "rate_limit") {
+		t.Errorf("response reports a rate limit while none is configured:\n%s", body)
+	}
+}
+
+func TestCapabilitiesReportEnabledFeatures(t *testing.T) {
+	useStrategy(t, shortener.SequentialStrategy{})
+	oldApproval, oldTracking := shortener.RequireApproval, shortener.ClickTracking
+	shortener.RequireApproval, shortener.ClickTracking = true, shortener.ClickTrackingDaily
+	t.Cleanup(func() { shortener.RequireApproval, shortener.ClickTracking = oldApproval, oldTracking })
+	s, _ := newTestStore()
+	s.redirectStatus = http.StatusMovedPermanently
+	s.apiKeys = []apiKey{{name: "ci", key: "secret"}}
+	s.shortenLimiter = newRateLimiter(2, 5)
+
+	resp, _ := getCapabilities(t, s)
+	want := CapabilityFeatures{
+		BatchShorten:   true,
+		KeyStrategy:    "sequential",
+		ApprovalNeeded: true,
+		DailyClicks:    true,
+		RedirectStatus: http.StatusMovedPermanently,
+		APIKeyRequired: true,
+	}
+	if resp.Features != want {
+		t.Errorf("features = %+v, want %+v", resp.Features, want)
+	}
+	if resp.Limits.RateLimitRPS != 2 || resp.Limits.RateLimitBurst != 5 {
+		t.Errorf("rate limit = %v/s burst %d, want 2/s burst 5", resp.Limits.RateLimitRPS, resp.Limits.RateLimitBurst)
+	}
+}
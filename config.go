@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setupLogging installs a JSON slog logger as the default at the given level ("debug",
+// "info", "warn", or "error"), defaulting to info. Messages from the standard log package
+// are routed through it as well.
+func setupLogging(level string) {
+	var logLevel slog.Level
+	if level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			fatal("LOG_LEVEL must be debug, info, warn, or error", "value", level)
+		}
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+}
+
+// sslModes are the DB_SSLMODE values lib/pq supports. libpq's "allow" and "prefer" are not
+// among them.
+var sslModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// parseDatabaseURL validates a postgres:// or postgresql:// connection URL, as given in
+// DATABASE_URL, and returns it with sslMode applied unless the URL sets sslmode itself. An
+// empty sslMode leaves lib/pq's default, require. The user and database name are returned
+// for error messages.
+func parseDatabaseURL(raw, sslMode string) (dsn, user, dbname string, err error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		// The error would echo the URL, password included
+		return "", "", "", errors.New("not a valid URL")
+	}
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return "", "", "", fmt.Errorf("scheme must be postgres or postgresql, not %q", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return "", "", "", errors.New("missing host")
+	}
+	if port := parsed.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", "", fmt.Errorf("invalid port %q", port)
+		}
+	}
+	dbname = strings.TrimPrefix(parsed.Path, "/")
+	if dbname == "" || strings.Contains(dbname, "/") {
+		return "", "", "", errors.New("path must be the database name")
+	}
+
+	query := parsed.Query()
+	if mode := query.Get("sslmode"); mode != "" {
+		if !sslModes[mode] {
+			return "", "", "", fmt.Errorf("sslmode must be disable, require, verify-ca, or verify-full, not %q", mode)
+		}
+	} else if sslMode != "" {
+		query.Set("sslmode", sslMode)
+		parsed.RawQuery = query.Encode()
+	}
+
+	return parsed.String(), parsed.User.Username(), dbname, nil
+}
+
+// fatal logs msg at error level and exits. It is only used for startup failures.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// getEnvBool reads a boolean environment variable, falling back to def when it is unset.
+// Invalid values are fatal so misconfiguration is caught at startup rather than at request time.
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		fatal("Environment variable must be a boolean", "key", key, "value", value)
+	}
+	return parsed
+}
+
+// getEnvInt reads an integer environment variable, falling back to def when it is unset.
+// Invalid values are fatal so misconfiguration is caught at startup rather than at request time.
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		fatal("Environment variable must be an integer", "key", key, "value", value)
+	}
+	return parsed
+}
+
+// getEnvFloat reads a floating point environment variable, falling back to def when it is unset.
+// Invalid values are fatal so misconfiguration is caught at startup rather than at request time.
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fatal("Environment variable must be a number", "key", key, "value", value)
+	}
+	return parsed
+}
+
+// getEnvDuration reads a duration environment variable such as "15s", falling back to def
+// when it is unset. Invalid or negative values are fatal.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		fatal("Environment variable must be a non-negative duration such as \"15s\"", "key", key, "value", value)
+	}
+	return parsed
+}
+
+// readPatternFile reads one pattern per line from path, skipping blank lines and lines
+// starting with "#".
+func readPatternFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// parseDomainList splits a comma-separated list of domains, dropping empty entries.
+func parseDomainList(list string) []string {
+	var domains []string
+	for _, domain := range strings.Split(list, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsAllowedMethods and corsAllowedHeaders are what preflight requests may ask for.
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Authorization, X-API-Key, Idempotency-Key"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight response.
+const corsMaxAge = "600"
+
+// parseCORSOrigins turns a comma-separated list of origins into a lookup set. An entry of
+// "*" allows every origin.
+func parseCORSOrigins(list string) map[string]bool {
+	origins := make(map[string]bool)
+	for _, origin := range strings.Split(list, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins[origin] = true
+		}
+	}
+	return origins
+}
+
+// corsOriginAllowed reports whether browsers on origin may call the API.
+func (s *Store) corsOriginAllowed(origin string) bool {
+	return s.corsOrigins["*"] || s.corsOrigins[origin]
+}
+
+// cors wraps an API handler so browsers on an allowed origin can call it. Preflight OPTIONS
+// requests are answered here without reaching the handler; requests from other origins get
+// no CORS headers, so the browser blocks them. With no origins configured it does nothing.
+func (s *Store) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(s.corsOrigins) == 0 {
+			next(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := s.corsOriginAllowed(origin)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		// Preflight: the browser is asking permission before sending the real request
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "origin not allowed"})
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
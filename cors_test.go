@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// corsRequest sends a request from origin through s.cors to a handler answering 200, and
+// reports whether the handler ran.
+func corsRequest(s *Store, method, origin string, header http.Header) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	handler := s.cors(func(w http.ResponseWriter, r *http.Request) { reached = true })
+	r := httptest.NewRequest(method, "/api/v1/shorten", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w, reached
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s, _ := newTestStore()
+	s.corsOrigins = parseCORSOrigins("https://app.example.com/, https://admin.example.com")
+	preflight := http.Header{
+		"Access-Control-Request-Method":  {"POST"},
+		"Access-Control-Request-Headers": {"content-type, idempotency-key"},
+	}
+
+	w, reached := corsRequest(s, http.MethodOptions, "https://app.example.com", preflight)
+	if w.Code != http.StatusNoContent || reached {
+		t.Errorf("allowed preflight = %d (handler reached %v), want 204 answered by the middleware", w.Code, reached)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": corsAllowedMethods,
+		"Access-Control-Allow-Headers": corsAllowedHeaders,
+		"Access-Control-Max-Age":       corsMaxAge,
+		"Vary":                         "Origin",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	w, reached = corsRequest(s, http.MethodOptions, "https://evil.example.com", preflight)
+	if w.Code != http.StatusForbidden || reached || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed preflight = %d with Allow-Origin %q (handler reached %v), want a bare 403",
+			w.Code, w.Header().Get("Access-Control-Allow-Origin"), reached)
+	}
+}
+
+func TestCORSActualRequests(t *testing.T) {
+	s, _ := newTestStore()
+	s.corsOrigins = parseCORSOrigins("https://app.example.com")
+
+	w, reached := corsRequest(s, http.MethodPost, "https://app.example.com", nil)
+	if !reached || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("allowed origin: handler reached %v, Allow-Origin %q", reached, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// The request still runs, but without CORS headers the browser hides the response
+	w, reached = corsRequest(s, http.MethodPost, "https://evil.example.com", nil)
+	if !reached || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin: handler reached %v, Allow-Origin %q", reached, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Same-origin and non-browser requests don't send Origin
+	if w, reached := corsRequest(s, http.MethodPost, "", nil); !reached || w.Header().Get("Vary") != "" {
+		t.Errorf("request without Origin: handler reached %v, Vary %q", reached, w.Header().Get("Vary"))
+	}
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	s, _ := newTestStore()
+	w, reached := corsRequest(s, http.MethodOptions, "https://app.example.com", http.Header{"Access-Control-Request-Method": {"POST"}})
+	if !reached || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("with no origins configured: handler reached %v, Allow-Origin %q", reached, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	s, _ := newTestStore()
+	s.corsOrigins = parseCORSOrigins("*")
+	w, _ := corsRequest(s, http.MethodGet, "https://anywhere.example", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example" {
+		t.Errorf("Allow-Origin = %q, want the request's origin echoed", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds the database ping in the readiness probe so a hung database
+// fails the probe instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleHealth is the liveness probe. It succeeds as long as the process can serve requests.
+func (s *Store) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// handleReady is the readiness probe. It reports 503 when the database can't be reached
+// within readinessTimeout, or once shutdown has started, so the load balancer stops routing
+// traffic here.
+func (s *Store) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.shuttingDown.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: "unavailable",
+			Error:  "shutting down",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: "unavailable",
+			Error:  "database unreachable",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
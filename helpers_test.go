@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+func TestMain(m *testing.M) {
+	// Handlers log every request; keep test output to failures
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// captureLogs sends the default logger's output to the returned buffer for the rest of the
+// test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &logs
+}
+
+// newTestStore returns a Store backed by an in-memory repository.
+func newTestStore() (*Store, *shortener.MemoryRepository) {
+	repo := shortener.NewMemoryRepository()
+	return &Store{repo: repo, redirectStatus: http.StatusFound, disabledStatus: http.StatusGone}, repo
+}
+
+// useStrategy switches the key strategy for the rest of the test.
+func useStrategy(t *testing.T, strategy shortener.KeyStrategy) {
+	t.Helper()
+	old := shortener.Strategy
+	shortener.Strategy = strategy
+	t.Cleanup(func() { shortener.Strategy = old })
+}
+
+// postShorten sends body to handleShorten with the given headers and returns the recorded
+// response and its decoded body.
+func postShorten(t *testing.T, s *Store, body string, header http.Header) (*httptest.ResponseRecorder, ShortenResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.handleShorten(w, r)
+
+	var resp ShortenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding shorten response %q: %v", w.Body.String(), err)
+	}
+	return w, resp
+}
+
+// createLink shortens longURL directly in repo and returns its key.
+func createLink(t *testing.T, repo shortener.Repository, longURL string, opts shortener.LinkOptions) string {
+	t.Helper()
+	result, err := shortener.HandleShortURLRequest(context.Background(), longURL, repo, opts)
+	if err != nil {
+		t.Fatalf("shortening %q: %v", longURL, err)
+	}
+	return result.ShortKey
+}
+
+// followLink sends a request for path to handleRedirect with the given User-Agent.
+func followLink(s *Store, method, path, userAgent string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.Header.Set("User-Agent", userAgent)
+	w := httptest.NewRecorder()
+	s.handleRedirect(w, r)
+	return w
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyHeader names the header clients set to make shorten requests safe to retry.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds client-chosen keys so they can't be used to fill memory.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotencyEntries caps how many responses are remembered at once. Past it, requests
+// are still served but not remembered until expired entries have been swept.
+const maxIdempotencyEntries = 100000
+
+// errIdempotencyKeyReused is returned when a key comes back with a different request body.
+var errIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+
+// idempotencyCache remembers the response to each shorten request sent with an
+// Idempotency-Key, so a client retrying after a timeout or dropped connection gets the
+// original response back, status code included, instead of the request being processed
+// again. State is in memory and per instance.
+type idempotencyCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// nextSweep is when expired entries are next removed
+	nextSweep time.Time
+}
+
+// idempotencyEntry is one remembered request. done is closed once the first request for
+// the key finishes, after which status, response, and expires are safe to read.
+type idempotencyEntry struct {
+	done chan struct{}
+	// fingerprint identifies the request body the key was first used with
+	fingerprint string
+	status      int
+	response    ShortenResponse
+	expires     time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// isValidIdempotencyKey reports whether key is a non-empty run of printable ASCII no
+// longer than maxIdempotencyKeyLength.
+func isValidIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// do runs shorten for the first request with key and returns its response to every request
+// with the same key that arrives while it is running or within the TTL after it finished,
+// reporting replayed for those. A key sent again with a different fingerprint gets
+// errIdempotencyKeyReused. Server errors (5xx), including a panicking shorten, are
+// forgotten so the client can retry.
+func (c *idempotencyCache) do(key, fingerprint string, shorten func() (int, ShortenResponse)) (status int, response ShortenResponse, replayed bool, err error) {
+	c.mu.Lock()
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(min(c.ttl, maxSweepInterval))
+	}
+	if e, ok := c.entries[key]; ok && (e.expires.IsZero() || !now.After(e.expires)) {
+		c.mu.Unlock()
+		if e.fingerprint != fingerprint {
+			return 0, ShortenResponse{}, false, errIdempotencyKeyReused
+		}
+		<-e.done
+		return e.status, e.response, true, nil
+	}
+	if len(c.entries) >= maxIdempotencyEntries {
+		c.mu.Unlock()
+		status, response := shorten()
+		return status, response, false, nil
+	}
+	// Stays set if shorten panics, so waiters get a 500 instead of blocking forever
+	e := &idempotencyEntry{
+		done:        make(chan struct{}),
+		fingerprint: fingerprint,
+		status:      http.StatusInternalServerError,
+		response:    ShortenResponse{Error: "internal server error"},
+	}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		e.expires = time.Now().Add(c.ttl)
+		if e.status >= http.StatusInternalServerError && c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		close(e.done)
+	}()
+
+	e.status, e.response = shorten()
+	return e.status, e.response, false, nil
+}
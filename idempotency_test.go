@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+func TestShortenIdempotencyKeyReplaysResponse(t *testing.T) {
+	// Sequential keys make a reprocessed request visible as a new link
+	useStrategy(t, shortener.SequentialStrategy{})
+	s, _ := newTestStore()
+	s.idempotency = newIdempotencyCache(time.Hour)
+
+	header := http.Header{"Idempotency-Key": {"retry-1"}}
+	body := `{"long_url": "https://example.com/page"}`
+	w1, first := postShorten(t, s, body, header)
+	w2, second := postShorten(t, s, body, header)
+
+	if w1.Code != http.StatusCreated || w2.Code != http.StatusCreated {
+		t.Errorf("statuses = %d, %d, want the original 201 replayed", w1.Code, w2.Code)
+	}
+	if second.ShortKey != first.ShortKey {
+		t.Errorf("replay returned key %q, want %q", second.ShortKey, first.ShortKey)
+	}
+	if w1.Header().Get("Idempotent-Replayed") != "" || w2.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Idempotent-Replayed = %q, %q, want only the replay marked",
+			w1.Header().Get("Idempotent-Replayed"), w2.Header().Get("Idempotent-Replayed"))
+	}
+
+	// A new key is a new request
+	_, third := postShorten(t, s, body, http.Header{"Idempotency-Key": {"retry-2"}})
+	if third.ShortKey == first.ShortKey {
+		t.Errorf("a different key replayed %q", first.ShortKey)
+	}
+}
+
+func TestShortenIdempotencyKeyReplaysClientErrors(t *testing.T) {
+	s, _ := newTestStore()
+	s.idempotency = newIdempotencyCache(time.Hour)
+
+	header := http.Header{"Idempotency-Key": {"bad-url"}}
+	w1, _ := postShorten(t, s, `{"long_url": "ftp://example.com/"}`, header)
+	w2, _ := postShorten(t, s, `{"long_url": "ftp://example.com/"}`, header)
+	if w1.Code != http.StatusBadRequest || w2.Code != http.StatusBadRequest {
+		t.Errorf("statuses = %d, %d, want 400 twice", w1.Code, w2.Code)
+	}
+	if w2.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("the rejected request was processed again instead of replayed")
+	}
+}
+
+func TestShortenIdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	s, _ := newTestStore()
+	s.idempotency = newIdempotencyCache(time.Hour)
+
+	header := http.Header{"Idempotency-Key": {"k"}}
+	postShorten(t, s, `{"long_url": "https://example.com/a"}`, header)
+	w, resp := postShorten(t, s, `{"long_url": "https://example.com/b"}`, header)
+	if w.Code != http.StatusUnprocessableEntity || resp.Error == "" {
+		t.Errorf("reused key = %d %+v, want 422 with an error", w.Code, resp)
+	}
+}
+
+func TestShortenIdempotencyKeyScopedByClient(t *testing.T) {
+	useStrategy(t, shortener.SequentialStrategy{})
+	s, _ := newTestStore()
+	s.idempotency = newIdempotencyCache(time.Hour)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", bytes.NewBufferString(`{"long_url": "https://example.com/"}`))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Idempotency-Key", "shared")
+		w := httptest.NewRecorder()
+		s.handleShorten(w, r)
+		return w
+	}
+	send("198.51.100.1:1000")
+	w := send("198.51.100.2:1000")
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("another client's request was replayed")
+	}
+}
+
+func TestShortenIdempotencyKeyValidated(t *testing.T) {
+	s, _ := newTestStore()
+	s.idempotency = newIdempotencyCache(time.Hour)
+
+	for _, key := range []string{string(make([]byte, maxIdempotencyKeyLength+1)), "tab\there", "é"} {
+		w, _ := postShorten(t, s, `{"long_url": "https://example.com/"}`, http.Header{"Idempotency-Key": {key}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Idempotency-Key %q got %d, want 400", key, w.Code)
+		}
+	}
+}
+
+func TestIdempotencyCacheForgetsServerErrors(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	c.do("k", "fp", func() (int, ShortenResponse) {
+		return http.StatusServiceUnavailable, ShortenResponse{Error: "busy"}
+	})
+	status, _, replayed, _ := c.do("k", "fp", func() (int, ShortenResponse) {
+		return http.StatusCreated, ShortenResponse{ShortKey: "abc1234"}
+	})
+	if status != http.StatusCreated || replayed {
+		t.Errorf("retry after a 503 = %d, replayed %v, want a fresh 201", status, replayed)
+	}
+}
+
+func TestIdempotencyCacheExpires(t *testing.T) {
+	c := newIdempotencyCache(10 * time.Millisecond)
+	shorten := func() (int, ShortenResponse) { return http.StatusCreated, ShortenResponse{ShortKey: "abc1234"} }
+	c.do("k", "fp", shorten)
+	time.Sleep(20 * time.Millisecond)
+	if _, _, replayed, _ := c.do("k", "fp", shorten); replayed {
+		t.Error("a request after the TTL was replayed")
+	}
+}
+
+func TestIdempotencyCachePanicDoesNotBlockRetries(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	started := make(chan struct{})
+	waiterDone := make(chan int)
+
+	go func() {
+		defer func() { recover() }()
+		c.do("k", "fp", func() (int, ShortenResponse) {
+			close(started)
+			// Give the second request time to start waiting on this one
+			time.Sleep(50 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+	go func() {
+		status, _, _, _ := c.do("k", "fp", func() (int, ShortenResponse) { return http.StatusTeapot, ShortenResponse{} })
+		waiterDone <- status
+	}()
+
+	select {
+	case status := <-waiterDone:
+		if status != http.StatusInternalServerError {
+			t.Errorf("waiter got %d, want 500", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request waiting on a panicked one never returned")
+	}
+
+	status, _, replayed, _ := c.do("k", "fp", func() (int, ShortenResponse) { return http.StatusCreated, ShortenResponse{} })
+	if status != http.StatusCreated || replayed {
+		t.Errorf("retry after the panic = %d, replayed %v, want a fresh 201", status, replayed)
+	}
+}
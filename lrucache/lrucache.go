@@ -0,0 +1,106 @@
+// Package lrucache is a bounded in-process cache implementing shortener.Cache. It keeps the
+// most recently used short keys in memory so hot redirects skip the network entirely.
+package lrucache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a size-bounded LRU of short key to long URL mappings whose entries also expire
+// after a TTL. It is safe for concurrent use. Each instance has its own cache, so entries
+// can be stale for up to the TTL after a link changes on another instance.
+type Cache struct {
+	size int
+	ttl  time.Duration
+	// now is the clock, replaceable so expiry can be tested
+	now func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	shortKey string
+	longURL  string
+	expires  time.Time
+}
+
+// New returns a cache holding at most size entries, each for at most ttl. ttl must be
+// positive: entries are already expired when they are set otherwise.
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// Get returns the cached long URL for shortKey, with ok false on a miss or an expired entry.
+func (c *Cache) Get(ctx context.Context, shortKey string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[shortKey]
+	if !ok {
+		return "", false, nil
+	}
+	e := elem.Value.(*entry)
+	if c.now().After(e.expires) {
+		c.removeElement(elem)
+		return "", false, nil
+	}
+	c.order.MoveToFront(elem)
+	return e.longURL, true, nil
+}
+
+// Set caches longURL for shortKey, evicting the least recently used entry when full.
+func (c *Cache) Set(ctx context.Context, shortKey, longURL string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[shortKey]; ok {
+		e := elem.Value.(*entry)
+		e.longURL = longURL
+		e.expires = expires
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[shortKey] = c.order.PushFront(&entry{shortKey: shortKey, longURL: longURL, expires: expires})
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+	return nil
+}
+
+// Delete removes any cached entry for shortKey.
+func (c *Cache) Delete(ctx context.Context, shortKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[shortKey]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries, including any that have expired but not yet
+// been evicted.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// removeElement drops elem from the cache. c.mu must be held.
+func (c *Cache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).shortKey)
+}
@@ -0,0 +1,133 @@
+package lrucache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCache returns a cache whose clock reads *now.
+func newTestCache(size int, ttl time.Duration, now *time.Time) *Cache {
+	c := New(size, ttl)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := newTestCache(2, time.Minute, &now)
+
+	c.Set(ctx, "a", "https://example.com/a")
+	c.Set(ctx, "b", "https://example.com/b")
+	// Reading a makes b the least recently used
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("Get(a) missed")
+	}
+	c.Set(ctx, "c", "https://example.com/c")
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b is still cached, want it evicted as least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if got, ok, _ := c.Get(ctx, key); !ok || got != "https://example.com/"+key {
+			t.Errorf("Get(%s) = %q, %v, want it cached", key, got, ok)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want the size bound 2", c.Len())
+	}
+}
+
+func TestSetReplacesAndRefreshes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := newTestCache(2, time.Minute, &now)
+
+	c.Set(ctx, "a", "https://example.com/old")
+	c.Set(ctx, "b", "https://example.com/b")
+	c.Set(ctx, "a", "https://example.com/new")
+	c.Set(ctx, "c", "https://example.com/c")
+
+	if got, ok, _ := c.Get(ctx, "a"); !ok || got != "https://example.com/new" {
+		t.Errorf("Get(a) = %q, %v, want the replaced URL, kept as recently used", got, ok)
+	}
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b is still cached, want it evicted")
+	}
+}
+
+func TestEntriesExpireAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := newTestCache(10, time.Minute, &now)
+	c.Set(ctx, "a", "https://example.com/a")
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Error("entry missed at exactly its TTL, want it cached until after")
+	}
+	now = now.Add(time.Nanosecond)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("entry still cached after its TTL")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d, want the expired entry removed when read", c.Len())
+	}
+
+	// Setting again starts a new TTL
+	c.Set(ctx, "a", "https://example.com/a")
+	now = now.Add(30 * time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Error("re-set entry missed within its TTL")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	c := New(10, time.Minute)
+	c.Set(ctx, "a", "https://example.com/a")
+	c.Delete(ctx, "a")
+	c.Delete(ctx, "missing")
+
+	if _, ok, _ := c.Get(ctx, "a"); ok || c.Len() != 0 {
+		t.Errorf("Get after Delete hit (Len %d), want a miss", c.Len())
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	const size = 16
+	c := New(size, time.Minute)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := fmt.Sprintf("k%d", (worker*7+i)%40)
+				switch i % 3 {
+				case 0:
+					c.Set(ctx, key, "https://example.com/"+key)
+				case 1:
+					if got, ok, _ := c.Get(ctx, key); ok && got != "https://example.com/"+key {
+						t.Errorf("Get(%s) = %q, want its own URL", key, got)
+					}
+				default:
+					c.Delete(ctx, key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > size {
+		t.Errorf("Len = %d after concurrent use, want at most %d", n, size)
+	}
+	if len(c.entries) != c.order.Len() {
+		t.Errorf("index has %d entries but the list has %d", len(c.entries), c.order.Len())
+	}
+}
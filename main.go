@@ -1,15 +1,26 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/shantanu747/URL-Shortener/lrucache"
+	"github.com/shantanu747/URL-Shortener/migrations"
+	"github.com/shantanu747/URL-Shortener/rediscache"
 	"github.com/shantanu747/URL-Shortener/shortener"
 
 	"github.com/joho/godotenv"
@@ -20,15 +31,197 @@ import (
 // Struct to hold our database connection
 type Store struct {
 	db *sql.DB
+	// repo stores links for the create and redirect paths
+	repo shortener.Repository
+	// adminAPIKey guards the admin endpoints; they are disabled when it is empty
+	adminAPIKey string
+	// reportAllValidationErrors lists every validation failure instead of only the first
+	reportAllValidationErrors bool
+	// reportNormalizedURL returns both forms of the URL from shorten when normalization changed it
+	reportNormalizedURL bool
+	// maxBatchSize is the most URLs accepted by the batch shorten endpoint
+	maxBatchSize int
+	// submitGuard returns the same link for repeated identical submissions, nil when disabled
+	submitGuard *submitGuard
+	// idempotency replays responses to shorten requests retried with an Idempotency-Key,
+	// nil when disabled
+	idempotency *idempotencyCache
+	// botUserAgents are lowercased User-Agent substrings whose requests don't count as clicks
+	botUserAgents []string
+	// botInfoPage serves bots a 200 info page instead of a redirect
+	botInfoPage bool
+	// trustProxy honors X-Forwarded-For when identifying clients behind a reverse proxy
+	trustProxy bool
+	// shortenLimiter rate limits link creation per client IP, nil when disabled
+	shortenLimiter *rateLimiter
+	// redirectStatus is the status code used for redirects (301, 302, 307, or 308)
+	redirectStatus int
+	// disabledStatus is the status code for redirects to disabled links, 410 or 404 to hide
+	// that the link exists
+	disabledStatus int
+	// apiKeys are the client keys accepted for link creation from API_KEYS
+	apiKeys []apiKey
+	// apiKeysFromDB also accepts keys from the api_keys table
+	apiKeysFromDB bool
+	// corsOrigins are the browser origins allowed to call the API, empty to disable CORS
+	corsOrigins map[string]bool
+	// shuttingDown fails the readiness probe once shutdown starts so no new traffic is routed here
+	shuttingDown atomic.Bool
 }
 
 type ShortenRequest struct {
 	LongURL string `json:"long_url"`
+	// ExpiresAt optionally stops the link resolving after this time (RFC 3339)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ExpiredRedirect optionally sends visitors here once the link has expired
+	ExpiredRedirect string `json:"expired_redirect,omitempty"`
+	// MaxClicks optionally stops the link resolving after this many redirects, 1 for single use
+	MaxClicks int `json:"max_clicks,omitempty"`
+}
+
+// linkOptions returns the per-link settings given in the request, attributed to the API key
+// that authenticated it.
+func (req ShortenRequest) linkOptions(ctx context.Context) shortener.LinkOptions {
+	return shortener.LinkOptions{
+		ExpiresAt:       req.ExpiresAt,
+		ExpiredRedirect: req.ExpiredRedirect,
+		CreatedBy:       apiKeyName(ctx),
+		MaxClicks:       req.MaxClicks,
+	}
 }
 
 type ShortenResponse struct {
 	ShortURL string `json:"short_url"`
-	Error    string `json:"error,omitempty"`
+	// ShortKey is the key alone, for clients building links on their own domains
+	ShortKey string `json:"short_key,omitempty"`
+	// Deduplicated is true when the URL already had a link and that link was returned
+	// instead of a new one; it is left out for new links
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// OriginalURL and NormalizedURL are the URL as submitted and as stored, returned when
+	// REPORT_NORMALIZED_URL is enabled and normalization changed it
+	OriginalURL   string `json:"original_url,omitempty"`
+	NormalizedURL string `json:"normalized_url,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// Errors lists every validation failure when REPORT_ALL_VALIDATION_ERRORS is enabled
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ExpandResponse struct {
+	LongURL string `json:"long_url"`
+}
+
+// UnfurlResponse is the link metadata served to chat apps and bots.
+type UnfurlResponse struct {
+	ShortKey string `json:"short_key"`
+	ShortURL string `json:"short_url"`
+	LongURL  string `json:"long_url"`
+	// Host is the destination host, for bots that display where a link leads
+	Host string `json:"host"`
+}
+
+type PreviewKeyResponse struct {
+	ShortKey string `json:"short_key"`
+	ShortURL string `json:"short_url"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as a JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// lookupErrorStatus maps an error from a short key lookup to an HTTP status code and a
+// client-facing message. Keys that exist but no longer resolve (expired or disabled) get 410
+// Gone so clients can tell them from unknown keys, which get 404. Unexpected errors are
+// hidden behind a generic 500.
+func lookupErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, shortener.ErrInvalidKeyLength):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, shortener.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, shortener.ErrDisabled):
+		return http.StatusGone, err.Error()
+	case errors.Is(err, shortener.ErrExpired):
+		return http.StatusGone, err.Error()
+	case errors.Is(err, shortener.ErrBudgetExceeded):
+		return http.StatusServiceUnavailable, err.Error()
+	case shortener.IsTimeout(err):
+		return http.StatusGatewayTimeout, "database timed out, try again later"
+	default:
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
+// shortenErrorStatus maps an error from HandleShortURLRequest to an HTTP status code and a
+// client-facing message. Only validation failures are the client's fault, anything else is
+// on our side and is hidden behind a generic 500.
+func shortenErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, shortener.ErrCapacityReached):
+		return http.StatusServiceUnavailable, err.Error()
+	case errors.Is(err, shortener.ErrDomainBlocked):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, shortener.ErrInvalidURL):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, shortener.ErrBudgetExceeded):
+		return http.StatusServiceUnavailable, err.Error()
+	case shortener.IsTimeout(err):
+		return http.StatusGatewayTimeout, "database timed out, try again later"
+	default:
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
+// logShortenError logs a failed shorten request. Client errors are expected and logged at
+// info level, server errors at error level with the underlying cause.
+func logShortenError(ctx context.Context, longURL string, status int, err error) {
+	logger := requestLogger(ctx)
+	if status >= http.StatusInternalServerError {
+		logger.Error("shorten failed", "long_url", longURL, "error", err)
+		return
+	}
+	logger.Info("shorten rejected", "long_url", longURL, "error", err)
+}
+
+// reservedPathSegments are the first path segments claimed by system routes. Requests
+// under them that no route handles fall through to the redirect handler, which answers 404
+// instead of looking them up as short keys. Add a segment here when adding a top-level route.
+var reservedPathSegments = map[string]bool{
+	"api":         true,
+	"healthz":     true,
+	"readyz":      true,
+	"metrics":     true,
+	"favicon.ico": true,
+	"robots.txt":  true,
+}
+
+// isReservedPath reports whether path falls under a reserved system route.
+func isReservedPath(path string) bool {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return reservedPathSegments[segment]
+}
+
+// isValidShortKey reports whether shortKey is non-empty and only contains base64 URL-safe
+// characters.
+func isValidShortKey(shortKey string) bool {
+	if shortKey == "" {
+		return false
+	}
+	for _, char := range shortKey {
+		if !((char >= 'A' && char <= 'Z') ||
+			(char >= 'a' && char <= 'z') ||
+			(char >= '0' && char <= '9') ||
+			char == '-' || char == '_') {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *Store) handleShorten(w http.ResponseWriter, r *http.Request) {
@@ -49,38 +242,114 @@ func (s *Store) handleShorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Requests sent with an Idempotency-Key are answered from the cache when retried
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" && s.idempotency != nil {
+		if !isValidIdempotencyKey(key) {
+			writeJSON(w, http.StatusBadRequest, ShortenResponse{
+				Error: "Idempotency-Key must be 1 to 255 printable ASCII characters",
+			})
+			return
+		}
+		fingerprint, _ := json.Marshal(req)
+		status, response, replayed, err := s.idempotency.do(s.requesterScope(r)+" "+key, string(fingerprint),
+			func() (int, ShortenResponse) { return s.shorten(r, req) })
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, ShortenResponse{Error: err.Error()})
+			return
+		}
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+		writeJSON(w, status, response)
+		return
+	}
+
+	status, response := s.shorten(r, req)
+	writeJSON(w, status, response)
+}
+
+// shorten validates req and creates or reuses its link, returning the status code and body
+// to respond with.
+func (s *Store) shorten(r *http.Request, req ShortenRequest) (int, ShortenResponse) {
 	// Validate that long_url field is not empty
 	if req.LongURL == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ShortenResponse{
+		return http.StatusBadRequest, ShortenResponse{
 			Error: "long_url field is required",
-		})
-		return
+		}
+	}
+
+	// Report every validation problem at once so the client can fix them in one go
+	if s.reportAllValidationErrors {
+		if errs := shortener.ValidateLongURLAll(req.LongURL); len(errs) > 0 {
+			status := http.StatusBadRequest
+			messages := make([]string, len(errs))
+			for i, err := range errs {
+				messages[i] = err.Error()
+				if errors.Is(err, shortener.ErrDomainBlocked) {
+					status = http.StatusForbidden
+				}
+			}
+			requestLogger(r.Context()).Info("shorten rejected",
+				"long_url", req.LongURL, "errors", messages)
+			return status, ShortenResponse{
+				Error:  "validation failed",
+				Errors: messages,
+			}
+		}
 	}
 
 	// Call the shortener logic
-	shortURL, err := shortener.HandleShortURLRequest(req.LongURL, s.db)
+	create := func() (shortener.ShortenResult, error) {
+		return shortener.HandleShortURLRequest(r.Context(), req.LongURL, s.repo, req.linkOptions(r.Context()))
+	}
+	var result shortener.ShortenResult
+	var err error
+	if s.submitGuard != nil {
+		result, err = s.submitGuard.do(submitKey(s.requesterScope(r), req.LongURL), create)
+	} else {
+		result, err = create()
+	}
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ShortenResponse{
-			Error: err.Error(),
-		})
-		return
+		status, message := shortenErrorStatus(err)
+		logShortenError(r.Context(), req.LongURL, status, err)
+		return status, ShortenResponse{Error: message}
 	}
+	requestLogger(r.Context()).Info("short url created",
+		"long_url", req.LongURL, "short_url", result.ShortURL, "created", result.Created)
 
-	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(ShortenResponse{
-		ShortURL: shortURL,
-	})
+	// An existing link reused for the same URL is reported as plain success, so clients can
+	// tell it apart from a new one. New links held for approval don't resolve yet, so they are
+	// reported as accepted rather than created.
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+		if shortener.RequireApproval {
+			status = http.StatusAccepted
+		}
+	}
+
+	resp := ShortenResponse{
+		ShortURL:     result.ShortURL,
+		ShortKey:     result.ShortKey,
+		Deduplicated: !result.Created,
+	}
+	// Let the client confirm what was stored when it differs from what was sent
+	if s.reportNormalizedURL && result.LongURL != req.LongURL {
+		resp.OriginalURL, resp.NormalizedURL = req.LongURL, result.LongURL
+	}
+	return status, resp
 }
 
 func (s *Store) handleRedirect(w http.ResponseWriter, r *http.Request) {
-	//path validation
-	if strings.HasPrefix(r.URL.Path, "/api/") {
+	// Only accept GET and HEAD requests
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Paths under system routes are never short keys
+	if isReservedPath(r.URL.Path) {
 		http.NotFound(w, r)
 		return
 	}
@@ -95,39 +364,604 @@ func (s *Store) handleRedirect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate characters (should only be base64 URL-safe characters)
-	for _, char := range shortKey {
-		if !((char >= 'A' && char <= 'Z') ||
-			(char >= 'a' && char <= 'z') ||
-			(char >= '0' && char <= '9') ||
-			char == '-' || char == '_') {
-			http.Error(w, "invalid short key format", http.StatusBadRequest)
-			return
-		}
+	if !isValidShortKey(shortKey) {
+		http.Error(w, "invalid short key format", http.StatusBadRequest)
+		return
 	}
 
-	// Call HandleRedirectRequest with proper arguments
-	longURL, err := shortener.HandleRedirectRequest(r.Context(), s.db, shortKey)
+	// Crawlers resolve the link without counting a click
+	isBot := s.isBot(r.UserAgent())
+
+	// Call HandleRedirectRequest with proper arguments. Bots and HEAD requests (link checkers
+	// validating the link without following it) get a read-only lookup so they aren't counted
+	// as visits; the server drops the body of HEAD responses.
+	var longURL string
+	var err error
+	if isBot || r.Method == http.MethodHead {
+		longURL, err = shortener.LookupLongURL(r.Context(), s.repo, shortKey)
+	} else {
+		longURL, err = shortener.HandleRedirectRequest(r.Context(), s.repo, shortKey)
+	}
 	if err != nil {
+		// Expired links with a replacement destination send visitors there instead
+		var expired *shortener.ExpiredError
+		if errors.As(err, &expired) && expired.ExpiredRedirect != "" {
+			if !isAbsoluteHTTPURL(expired.ExpiredRedirect) {
+				requestLogger(r.Context()).Error("stored expired_redirect is not an absolute http(s) URL",
+					"short_key", shortKey, "expired_redirect", expired.ExpiredRedirect)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, expired.ExpiredRedirect, http.StatusFound)
+			return
+		}
+
 		//Check error type to determine proper status code
-		if strings.Contains(err.Error(), "invalid short key length") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		status, message := lookupErrorStatus(err)
+		if errors.Is(err, shortener.ErrDisabled) && s.disabledStatus == http.StatusNotFound {
+			// Visitors can't tell the link ever existed
+			status, message = http.StatusNotFound, shortener.ErrNotFound.Error()
 		}
+		if status == http.StatusInternalServerError {
+			requestLogger(r.Context()).Error("redirect failed", "short_key", shortKey, "error", err)
+		}
+		http.Error(w, message, status)
+		return
+	}
+	requestLogger(r.Context()).Debug("redirect resolved", "short_key", shortKey, "long_url", longURL, "bot", isBot, "method", r.Method)
+
+	// Links are validated when created, but rows written out-of-band could hold a relative or
+	// scheme-less URL, which http.Redirect would resolve against our own host
+	if !isAbsoluteHTTPURL(longURL) {
+		requestLogger(r.Context()).Error("stored long URL is not an absolute http(s) URL",
+			"short_key", shortKey, "long_url", longURL)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Carry query parameters added at click time (such as utm tags) through to the destination
+	longURL = mergeQuery(longURL, r.URL.Query())
+
+	// Note where counted visits came from; this never blocks or fails the redirect
+	if !isBot && r.Method == http.MethodGet && shortener.ClickEvents != nil {
+		if !shortener.ClickEvents.Record(shortKey, r.Referer(), r.UserAgent()) {
+			requestLogger(r.Context()).Debug("click event buffer full, dropping event", "short_key", shortKey)
+		}
+	}
+
+	if isBot && s.botInfoPage {
+		serveBotInfoPage(w, longURL)
 		return
 	}
 
 	// Redirect to the long URL
-	http.Redirect(w, r, longURL, http.StatusFound)
+	http.Redirect(w, r, longURL, s.redirectStatus)
+}
+
+// isAbsoluteHTTPURL reports whether rawURL is safe to redirect to: an absolute http or https
+// URL with a host.
+func isAbsoluteHTTPURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	return (scheme == "http" || scheme == "https") && parsed.Host != ""
+}
+
+// mergeQuery adds the params to longURL's query string, skipping any the stored URL already
+// sets, so the link owner's parameters win on conflicts. The stored query is kept as is, and
+// so is the stored fragment, which stays after the merged query.
+//
+// Fragments on the short link itself (/abc1234#section) can't be forwarded: browsers never
+// send them to the server. They do reapply them to the redirect target when it has no
+// fragment of its own, so most clients keep them anyway.
+func mergeQuery(longURL string, params url.Values) string {
+	if len(params) == 0 {
+		return longURL
+	}
+	parsed, err := url.Parse(longURL)
+	if err != nil {
+		return longURL
+	}
+
+	stored := parsed.Query()
+	extra := url.Values{}
+	for key, values := range params {
+		if _, ok := stored[key]; !ok {
+			extra[key] = values
+		}
+	}
+	if len(extra) == 0 {
+		return longURL
+	}
+
+	if parsed.RawQuery == "" {
+		parsed.RawQuery = extra.Encode()
+	} else {
+		parsed.RawQuery += "&" + extra.Encode()
+	}
+	return parsed.String()
+}
+
+// DailyClicksResponse is the click time series for a short key.
+type DailyClicksResponse struct {
+	ShortKey string                  `json:"short_key"`
+	From     string                  `json:"from"`
+	To       string                  `json:"to"`
+	Days     []shortener.DailyClicks `json:"days"`
+}
+
+// defaultClicksRange is how many days of clicks are returned when no range is given.
+const defaultClicksRange = 30
+
+// handleDailyClicks returns per-day click totals for a short key. The optional from and to
+// query parameters are inclusive YYYY-MM-DD dates and default to the last 30 days.
+func (s *Store) handleDailyClicks(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("key")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "to must be a YYYY-MM-DD date"})
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultClicksRange - 1))
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "from must be a YYYY-MM-DD date"})
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "from must not be after to"})
+		return
+	}
+
+	days, err := shortener.GetDailyClicks(r.Context(), s.db, shortKey, from, to)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DailyClicksResponse{
+		ShortKey: shortKey,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Days:     days,
+	})
+}
+
+// ReferrersResponse is the top referrers of a short key.
+type ReferrersResponse struct {
+	ShortKey  string                     `json:"short_key"`
+	Referrers []shortener.ReferrerClicks `json:"referrers"`
+}
+
+// handleReferrers returns the referrers that sent the most clicks to a short key, up to the
+// optional limit query parameter (default 10, at most shortener.MaxListLimit).
+func (s *Store) handleReferrers(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("key")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	limit := defaultReferrersLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > shortener.MaxListLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: fmt.Sprintf("limit must be between 1 and %d", shortener.MaxListLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	referrers, err := shortener.GetTopReferrers(r.Context(), s.db, shortKey, limit)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ReferrersResponse{ShortKey: shortKey, Referrers: referrers})
+}
+
+// defaultReferrersLimit is how many referrers are returned when no limit is given.
+const defaultReferrersLimit = 10
+
+func (s *Store) handleStats(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("key")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	stats, err := shortener.GetURLStats(r.Context(), s.db, shortKey)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+	if wantFavicon(r) {
+		stats.FaviconURL = shortener.FaviconURL(stats.LongURL)
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// wantFavicon reports whether a stats request opted in to the favicon_url field with ?favicon=true.
+func wantFavicon(r *http.Request) bool {
+	want, _ := strconv.ParseBool(r.URL.Query().Get("favicon"))
+	return want
+}
+
+// handleStatsByID returns the stored record for a link looked up by its database ID.
+func (s *Store) handleStatsByID(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "id must be a positive integer"})
+		return
+	}
+
+	stats, err := shortener.GetURLStatsByID(r.Context(), s.db, id)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+	if wantFavicon(r) {
+		stats.FaviconURL = shortener.FaviconURL(stats.LongURL)
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ListURLsResponse is one page of links from the list endpoint.
+type ListURLsResponse struct {
+	URLs   []shortener.URLStats `json:"urls"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// handleListURLs pages through all links, newest first, with ?limit= and ?offset=.
+func (s *Store) handleListURLs(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := shortener.DefaultListLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > shortener.MaxListLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: fmt.Sprintf("limit must be between 1 and %d", shortener.MaxListLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if raw := query.Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "offset must be a non-negative integer"})
+			return
+		}
+		offset = parsed
+	}
+
+	links, total, err := shortener.ListURLs(r.Context(), s.db, limit, offset)
+	if err != nil {
+		requestLogger(r.Context()).Error("list urls failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListURLsResponse{URLs: links, Total: total, Limit: limit, Offset: offset})
+}
+
+// handleExpand resolves a short key to its long URL without redirecting or counting a click.
+// The key is taken from the path, or from the key query parameter on /api/v1/expand.
+func (s *Store) handleExpand(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("shortKey")
+	if shortKey == "" {
+		shortKey = r.URL.Query().Get("key")
+	}
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	longURL, err := shortener.LookupLongURL(r.Context(), s.repo, shortKey)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ExpandResponse{LongURL: longURL})
+}
+
+// handleUnfurl serves link metadata to chat apps and bots so they can preview a link
+// without following the redirect, which would count as a click.
+func (s *Store) handleUnfurl(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("shortKey")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	longURL, err := shortener.LookupLongURL(r.Context(), s.repo, shortKey)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+
+	shortURL, err := shortener.GenerateFullShortURL(shortKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	resp := UnfurlResponse{ShortKey: shortKey, ShortURL: shortURL, LongURL: longURL}
+	if parsed, err := url.Parse(longURL); err == nil {
+		resp.Host = parsed.Hostname()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handlePreviewKey reports the key a URL would be shortened to without saving anything.
+// The key is not reserved until the URL is actually shortened.
+func (s *Store) handlePreviewKey(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	longURL := r.URL.Query().Get("url")
+	if longURL == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "url query parameter is required"})
+		return
+	}
+
+	shortKey, shortURL, err := shortener.PreviewShortKey(longURL)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if !errors.Is(err, shortener.ErrInvalidURL) {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PreviewKeyResponse{ShortKey: shortKey, ShortURL: shortURL})
+}
+
+// requireAdmin wraps an admin handler so it only runs for requests carrying the admin API key
+// as a bearer token. All admin endpoints are disabled when no key is configured.
+func (s *Store) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminAPIKey == "" {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "admin API is disabled"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminAPIKey)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleKeygenConfig reports the active key generation settings.
+func (s *Store) handleKeygenConfig(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shortener.CurrentKeygenConfig())
+}
+
+func (s *Store) handleApprove(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("shortKey")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	err := shortener.ApproveShortURL(r.Context(), s.db, shortKey)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateURLRequest is the body of a PATCH or PUT to /api/v1/urls/{key}. Fields left out are
+// unchanged, except that PUT requires long_url.
+type UpdateURLRequest struct {
+	// LongURL points the link at a new destination
+	LongURL string `json:"long_url,omitempty"`
+	// Active disables (false) or re-enables (true) the link
+	Active *bool `json:"active,omitempty"`
+}
+
+// handleUpdateURL changes an existing link and returns its updated record. PUT replaces the
+// link's destination while keeping its key and analytics; PATCH changes any of its settings,
+// such as disabling it, which stops it resolving while keeping its analytics.
+func (s *Store) handleUpdateURL(w http.ResponseWriter, r *http.Request) {
+	// Only accept PATCH and PUT requests
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PATCH, PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortKey := r.PathValue("key")
+	if !isValidShortKey(shortKey) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid short key format"})
+		return
+	}
+
+	var req UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON format"})
+		return
+	}
+	if r.Method == http.MethodPut && req.LongURL == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "long_url field is required"})
+		return
+	}
+
+	if req.LongURL != "" {
+		if err := shortener.UpdateLongURL(r.Context(), s.db, shortKey, req.LongURL); err != nil {
+			status, message := shortenErrorStatus(err)
+			if errors.Is(err, shortener.ErrNotFound) || errors.Is(err, shortener.ErrInvalidKeyLength) {
+				status, message = lookupErrorStatus(err)
+			}
+			logShortenError(r.Context(), req.LongURL, status, err)
+			writeJSON(w, status, ErrorResponse{Error: message})
+			return
+		}
+	}
+
+	if req.Active != nil {
+		update := shortener.DisableShortURL
+		if *req.Active {
+			update = shortener.EnableShortURL
+		}
+		if err := update(r.Context(), s.db, shortKey); err != nil {
+			status, message := lookupErrorStatus(err)
+			writeJSON(w, status, ErrorResponse{Error: message})
+			return
+		}
+	}
+
+	stats, err := shortener.GetURLStats(r.Context(), s.db, shortKey)
+	if err != nil {
+		status, message := lookupErrorStatus(err)
+		writeJSON(w, status, ErrorResponse{Error: message})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// maxConnectBackoff caps the wait between startup connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// pingWithRetry pings db up to attempts times, waiting backoff after the first failure and
+// doubling the wait after each one up to maxConnectBackoff. Errors that waiting won't fix,
+// such as a wrong password or a missing database, are returned right away.
+func pingWithRetry(db *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.Ping(); err == nil || !isTransientConnectError(err) || attempt == attempts {
+			return err
+		}
+		slog.Warn("Database not reachable yet, retrying",
+			"attempt", attempt, "max_attempts", attempts, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	return err
+}
+
+// isTransientConnectError reports whether a failed connection attempt is worth retrying.
+// Errors from PostgreSQL itself are final, except that it is still starting up or out of
+// connection slots; anything else, such as a refused connection, may clear up.
+func isTransientConnectError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P03", "53300": // cannot_connect_now, too_many_connections
+			return true
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 func main() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
+
+	// Structured JSON logs, configured first so every later message uses them
+	setupLogging(os.Getenv("LOG_LEVEL"))
 	if err != nil {
-		log.Println("Note: .env file not found, reading from system environment variables")
+		slog.Info("Note: .env file not found, reading from system environment variables")
 	}
 
 	// Read database configuration from environment variables
@@ -137,60 +971,523 @@ func main() {
 	password := os.Getenv("DB_PASSWORD")
 	dbname := os.Getenv("DB_NAME")
 
-	// Construct the connection string
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		host, db_port, user, password, dbname)
+	// TLS is off by default for local development; managed databases usually need require
+	// or stricter
+	sslMode := os.Getenv("DB_SSLMODE")
+	if sslMode != "" && !sslModes[sslMode] {
+		fatal("DB_SSLMODE must be disable, require, verify-ca, or verify-full", "value", sslMode)
+	}
+
+	var psqlInfo string
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		// A full connection URL, as hosting platforms provide, overrides the individual settings
+		psqlInfo, user, dbname, err = parseDatabaseURL(databaseURL, sslMode)
+		if err != nil {
+			fatal("Invalid DATABASE_URL", "error", err)
+		}
+	} else {
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		// Construct the connection string
+		psqlInfo = fmt.Sprintf("host=%s port=%s user=%s "+
+			"password=%s dbname=%s sslmode=%s",
+			host, db_port, user, password, dbname, sslMode)
+	}
 
 	// Open a connection to the database
 	db, err := sql.Open("postgres", psqlInfo)
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		fatal("Error opening database", "error", err)
+	}
+
+	// Size the connection pool; the defaults match database/sql's (unlimited open, 2 idle)
+	maxOpenConns := getEnvInt("DB_MAX_OPEN_CONNS", 0)
+	maxIdleConns := getEnvInt("DB_MAX_IDLE_CONNS", 2)
+	connMaxLifetime := getEnvDuration("DB_CONN_MAX_LIFETIME", 0)
+	if maxOpenConns < 0 {
+		fatal("DB_MAX_OPEN_CONNS must not be negative", "value", maxOpenConns)
 	}
-	defer db.Close()
+	if maxIdleConns < 0 {
+		fatal("DB_MAX_IDLE_CONNS must not be negative", "value", maxIdleConns)
+	}
+	if maxOpenConns > 0 && maxIdleConns > maxOpenConns {
+		fatal("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS",
+			"max_idle_conns", maxIdleConns, "max_open_conns", maxOpenConns)
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+	slog.Info("Database connection pool configured",
+		"max_open_conns", maxOpenConns,
+		"max_idle_conns", maxIdleConns,
+		"conn_max_lifetime", connMaxLifetime.String())
 
-	// Ping the database to verify the connection is alive
-	err = db.Ping()
+	// Ping the database to verify the connection is alive, waiting for it if it is still
+	// starting up, as is common under container orchestration
+	connectAttempts := getEnvInt("DB_CONNECT_ATTEMPTS", 10)
+	if connectAttempts < 1 {
+		fatal("DB_CONNECT_ATTEMPTS must be at least 1", "value", connectAttempts)
+	}
+	err = pingWithRetry(db, connectAttempts, getEnvDuration("DB_CONNECT_BACKOFF", time.Second))
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) {
-			log.Printf("PostgreSQL Error Detected:")
-			log.Printf("Error Code: %s", pqErr.Code)
-			log.Printf("Error Message: %s", pqErr.Message)
-			log.Printf("Details: %s", pqErr.Detail)
+			slog.Error("PostgreSQL Error Detected",
+				"code", string(pqErr.Code),
+				"message", pqErr.Message,
+				"detail", pqErr.Detail)
 
 			// Provide user friendly error messages for common issues
 			switch pqErr.Code {
 			case "28P01":
-				log.Fatalf("FATAL: Invalid password for user '%s'. Please check your .env file.", user)
+				fatal("Invalid password for database user, please check your .env file", "user", user)
 			case "3D000":
-				log.Fatalf("FATAL: Database '%s' does not exist. Please create it.", dbname)
+				fatal("Database does not exist, please create it", "database", dbname)
 			default:
-				log.Fatalf("FATAL: Unhandled PostgreSQL error: %v", pqErr)
+				fatal("Unhandled PostgreSQL error", "error", pqErr)
 			}
 		} else {
 			// This is a different kind of issue (e.g. network error)
-			log.Fatalf("Error connecting to the database: %v", err)
+			fatal("Error connecting to the database", "error", err)
 		}
 	}
-	fmt.Println("Successfully connected to the PostgreSQL database!")
+	slog.Info("Successfully connected to the PostgreSQL database")
+
+	// Optionally bring the schema up to date before serving
+	if getEnvBool("RUN_MIGRATIONS", false) {
+		applied, err := migrations.Run(context.Background(), db)
+		if err != nil {
+			fatal("Database migration failed", "applied", applied, "error", err)
+		}
+		slog.Info("Database schema is up to date", "applied", applied)
+	}
+
+	// Configure the generated short key length
+	if err := shortener.SetKeyLength(getEnvInt("KEY_LENGTH", shortener.DefaultKeyLength)); err != nil {
+		fatal("Invalid KEY_LENGTH", "error", err)
+	}
+	if err := shortener.SetMaxURLLength(getEnvInt("MAX_URL_LENGTH", shortener.DefaultMaxURLLength)); err != nil {
+		fatal("Invalid MAX_URL_LENGTH", "error", err)
+	}
+
+	// Optionally cache redirect lookups in Redis
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		ttl := getEnvDuration("REDIS_CACHE_TTL", time.Hour)
+		if ttl <= 0 {
+			fatal("REDIS_CACHE_TTL must be positive", "value", ttl.String())
+		}
+		timeout := getEnvDuration("REDIS_TIMEOUT", rediscache.DefaultTimeout)
+		if timeout <= 0 {
+			fatal("REDIS_TIMEOUT must be positive", "value", timeout.String())
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		cache, err := rediscache.New(ctx, redisURL, ttl, timeout)
+		cancel()
+		if err != nil {
+			fatal("Could not connect to REDIS_URL", "error", err)
+		}
+		defer cache.Close()
+		shortener.RedirectCache = cache
+		slog.Info("Redirect cache enabled")
+	}
+
+	// Or cache the hottest redirects in process. Entries can be stale for up to LOCAL_CACHE_TTL,
+	// and click_count only skips the database too when CLICK_FLUSH_INTERVAL is also set.
+	if size := getEnvInt("LOCAL_CACHE_SIZE", 0); size > 0 {
+		if shortener.RedirectCache != nil {
+			fatal("LOCAL_CACHE_SIZE and REDIS_URL cannot both be set")
+		}
+		ttl := getEnvDuration("LOCAL_CACHE_TTL", time.Minute)
+		if ttl <= 0 {
+			fatal("LOCAL_CACHE_TTL must be positive", "value", ttl.String())
+		}
+		shortener.RedirectCache = lrucache.New(size, ttl)
+		slog.Info("In-memory redirect cache enabled", "size", size)
+	}
+
+	// Choose whether clicks are also rolled up per day
+	if mode := os.Getenv("CLICK_TRACKING"); mode != "" {
+		if err := shortener.SetClickTracking(mode); err != nil {
+			fatal("Invalid CLICK_TRACKING", "error", err)
+		}
+	}
+
+	// Optionally count clicks in the background instead of on the redirect path
+	var clicks *shortener.ClickBuffer
+	if interval := getEnvDuration("CLICK_FLUSH_INTERVAL", 0); interval > 0 {
+		size := getEnvInt("CLICK_BUFFER_SIZE", 10000)
+		if size <= 0 {
+			fatal("CLICK_BUFFER_SIZE must be positive", "value", size)
+		}
+		flushEvents := getEnvInt("CLICK_FLUSH_EVENTS", 0)
+		if flushEvents < 0 {
+			fatal("CLICK_FLUSH_EVENTS must not be negative", "value", flushEvents)
+		}
+		clicks = shortener.NewClickBuffer(db, size, interval, flushEvents)
+		clicks.Start()
+		shortener.Clicks = clicks
+		slog.Info("Buffering click counts", "flush_interval", interval.String(), "flush_events", flushEvents)
+	}
+
+	// Optionally record the referrer and user agent of each counted redirect
+	var clickEvents *shortener.ClickEventRecorder
+	if getEnvBool("CLICK_EVENTS", false) {
+		size := getEnvInt("CLICK_EVENTS_BUFFER_SIZE", 10000)
+		if size <= 0 {
+			fatal("CLICK_EVENTS_BUFFER_SIZE must be positive", "value", size)
+		}
+		interval := getEnvDuration("CLICK_EVENTS_FLUSH_INTERVAL", 5*time.Second)
+		if interval <= 0 {
+			fatal("CLICK_EVENTS_FLUSH_INTERVAL must be positive", "value", interval.String())
+		}
+		clickEvents = shortener.NewClickEventRecorder(db, size, interval)
+		clickEvents.Start()
+		shortener.ClickEvents = clickEvents
+		slog.Info("Recording click events", "flush_interval", interval.String())
+	}
+
+	// Choose how short keys are generated
+	strategyName := os.Getenv("KEY_STRATEGY")
+	if strategyName == "" {
+		strategyName = "hash"
+	}
+	strategy, err := shortener.NewKeyStrategy(strategyName)
+	if err != nil {
+		fatal("Invalid KEY_STRATEGY", "error", err)
+	}
+	shortener.Strategy = strategy
+
+	// Mix a server secret into hashed keys so they can't be predicted from the URL. Changing
+	// it later only affects links created afterwards.
+	if secret := os.Getenv("KEY_SECRET"); secret != "" {
+		if len(secret) < 16 {
+			slog.Warn("KEY_SECRET is short, use at least 16 random characters", "length", len(secret))
+		}
+		shortener.KeySecret = []byte(secret)
+	}
+
+	// Optionally treat URLs differing only by a trailing slash as the same link
+	shortener.StripTrailingSlash = getEnvBool("NORMALIZE_TRAILING_SLASH", false)
+	// Redirect to the URL as its first creator typed it, while still deduplicating on the
+	// normalized form
+	shortener.KeepOriginalURL = getEnvBool("KEEP_ORIGINAL_URL", false)
+
+	// Look favicons up through a third-party service instead of at the destination
+	shortener.FaviconService = os.Getenv("FAVICON_SERVICE")
+	if shortener.FaviconService != "" && !strings.Contains(shortener.FaviconService, shortener.FaviconHostPlaceholder) {
+		fatal("FAVICON_SERVICE must contain the {host} placeholder", "value", shortener.FaviconService)
+	}
+	// Public URL of the short links, which is also used to refuse links back to ourselves
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		parsed, err := url.Parse(baseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			fatal("BASE_URL must be an absolute http or https URL", "value", baseURL)
+		}
+		shortener.BaseURL = baseURL
+	}
+	slog.Info("Short links configured", "base_url", shortener.BaseURL)
+
+	shortener.AllowUserinfo = getEnvBool("ALLOW_URL_USERINFO", false)
+	shortener.RejectMixedScriptHosts = getEnvBool("REJECT_MIXED_SCRIPT_HOSTS", true)
+
+	// Optionally resolve hostnames so names pointing at private addresses are rejected too
+	shortener.ResolveHosts = getEnvBool("RESOLVE_HOSTS", false)
+	shortener.ResolveTimeout = getEnvDuration("RESOLVE_TIMEOUT", shortener.ResolveTimeout)
+
+	// Probe destinations before shortening them, to refuse links to dead pages
+	shortener.CheckReachability = getEnvBool("CHECK_REACHABILITY", false)
+	shortener.ReachabilityTimeout = getEnvDuration("REACHABILITY_TIMEOUT", shortener.ReachabilityTimeout)
+	if shortener.ReachabilityTimeout <= 0 {
+		fatal("REACHABILITY_TIMEOUT must be positive", "value", shortener.ReachabilityTimeout)
+	}
+
+	// Reject URLs matching operator-supplied regexes, failing fast on invalid patterns
+	if path := os.Getenv("URL_DENYLIST_FILE"); path != "" {
+		patterns, err := readPatternFile(path)
+		if err != nil {
+			fatal("Could not read URL_DENYLIST_FILE", "error", err)
+		}
+		if err := shortener.SetURLDenylist(patterns); err != nil {
+			fatal("Invalid URL_DENYLIST_FILE", "error", err)
+		}
+		slog.Info("Loaded URL denylist", "patterns", len(patterns))
+	}
+
+	// Restrict which destination domains may be shortened
+	allowDomains := parseDomainList(os.Getenv("DOMAIN_ALLOWLIST"))
+	blockDomains := parseDomainList(os.Getenv("DOMAIN_BLOCKLIST"))
+	if path := os.Getenv("DOMAIN_ALLOWLIST_FILE"); path != "" {
+		domains, err := readPatternFile(path)
+		if err != nil {
+			fatal("Could not read DOMAIN_ALLOWLIST_FILE", "error", err)
+		}
+		allowDomains = append(allowDomains, domains...)
+	}
+	if path := os.Getenv("DOMAIN_BLOCKLIST_FILE"); path != "" {
+		domains, err := readPatternFile(path)
+		if err != nil {
+			fatal("Could not read DOMAIN_BLOCKLIST_FILE", "error", err)
+		}
+		blockDomains = append(blockDomains, domains...)
+	}
+	if err := shortener.SetDomainLists(allowDomains, blockDomains); err != nil {
+		fatal("Invalid destination domain list", "error", err)
+	}
+	if len(allowDomains) > 0 || len(blockDomains) > 0 {
+		slog.Info("Loaded destination domain lists", "allowed", len(allowDomains), "blocked", len(blockDomains))
+	}
+
+	// Global safety valve on how many links can be created per day and month
+	dailyCap := getEnvInt("DAILY_LINK_CAP", 0)
+	monthlyCap := getEnvInt("MONTHLY_LINK_CAP", 0)
+	if dailyCap > 0 || monthlyCap > 0 {
+		shortener.CreationCap = shortener.NewCreationLimit(dailyCap, monthlyCap)
+		slog.Info("Link creation capped (0 means unlimited)", "daily", dailyCap, "monthly", monthlyCap)
+	}
+
+	// Links on other shorteners (bit.ly and the like) are allowed, rejected, or resolved
+	shortenerMode := os.Getenv("KNOWN_SHORTENERS_MODE")
+	if shortenerMode == "" {
+		shortenerMode = shortener.KnownShortenersAllow
+	}
+	if err := shortener.SetKnownShorteners(shortenerMode, parseDomainList(os.Getenv("KNOWN_SHORTENERS"))); err != nil {
+		fatal("Invalid KNOWN_SHORTENERS_MODE", "error", err)
+	}
+
+	// Audit events go to stdout, apart from the request logs on stderr
+	if getEnvBool("AUDIT_LOG", false) {
+		shortener.AuditLog = shortener.NewAuditLogger(os.Stdout)
+	}
+
+	// Hold new links for approval in moderated deployments
+	shortener.RequireApproval = getEnvBool("REQUIRE_APPROVAL", false)
+	if shortener.RequireApproval && os.Getenv("ADMIN_API_KEY") == "" {
+		slog.Warn("REQUIRE_APPROVAL is enabled but ADMIN_API_KEY is not set, links cannot be approved")
+	}
+
 	// API Server Setup
-	store := &Store{db: db}
+	store := &Store{
+		db:                        db,
+		repo:                      shortener.NewPostgresRepository(db),
+		adminAPIKey:               os.Getenv("ADMIN_API_KEY"),
+		reportAllValidationErrors: getEnvBool("REPORT_ALL_VALIDATION_ERRORS", false),
+		reportNormalizedURL:       getEnvBool("REPORT_NORMALIZED_URL", false),
+		maxBatchSize:              getEnvInt("MAX_BATCH_SIZE", DefaultMaxBatchSize),
+		redirectStatus:            getEnvInt("REDIRECT_STATUS", http.StatusFound),
+		disabledStatus:            getEnvInt("DISABLED_LINK_STATUS", http.StatusGone),
+		trustProxy:                getEnvBool("TRUST_PROXY", false),
+		corsOrigins:               parseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS")),
+	}
+	// The creation rate limit can be given per second or per minute, but not both
+	rps := getEnvFloat("RATE_LIMIT_RPS", 0)
+	if rpm := getEnvFloat("RATE_LIMIT_RPM", 0); rpm > 0 {
+		if rps > 0 {
+			fatal("Set only one of RATE_LIMIT_RPS and RATE_LIMIT_RPM")
+		}
+		rps = rpm / 60
+	}
+	if rps > 0 {
+		burst := getEnvInt("RATE_LIMIT_BURST", 10)
+		if burst < 1 {
+			fatal("RATE_LIMIT_BURST must be at least 1", "value", burst)
+		}
+		store.shortenLimiter = newRateLimiter(rps, burst)
+		slog.Info("Rate limiting link creation per client IP", "rps", rps, "burst", burst)
+	}
+	switch store.redirectStatus {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		fatal("REDIRECT_STATUS must be 301, 302, 307, or 308", "value", store.redirectStatus)
+	}
+	if store.disabledStatus != http.StatusNotFound && store.disabledStatus != http.StatusGone {
+		fatal("DISABLED_LINK_STATUS must be 404 or 410", "value", store.disabledStatus)
+	}
+	apiKeys, err := parseAPIKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		fatal("Invalid API_KEYS", "error", err)
+	}
+	store.apiKeys = apiKeys
+	store.apiKeysFromDB = getEnvBool("API_KEYS_DB", false)
+	if agents := os.Getenv("BOT_USER_AGENTS"); agents != "" {
+		store.botUserAgents = parseBotUserAgents(agents)
+		store.botInfoPage = getEnvBool("BOT_INFO_PAGE", false)
+	}
+	if window := getEnvDuration("DUPLICATE_SUBMIT_WINDOW", 0); window > 0 {
+		store.submitGuard = newSubmitGuard(window)
+	}
+	if ttl := getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour); ttl > 0 {
+		store.idempotency = newIdempotencyCache(ttl)
+	}
+	if store.maxBatchSize <= 0 {
+		fatal("MAX_BATCH_SIZE must be positive", "value", store.maxBatchSize)
+	}
 	mux := http.NewServeMux()
 
+	// API routes are wrapped in store.cors so browser front-ends on CORS_ALLOWED_ORIGINS can
+	// call them; the redirect route is left alone
+
+	// Health probes for the load balancer, registered before the catch-all redirect handler
+	mux.HandleFunc("/healthz", store.handleHealth)
+	mux.HandleFunc("/readyz", store.handleReady)
+
+	// Handle the API endpoint for feature-detecting this instance
+	mux.HandleFunc("/api/v1/capabilities", store.cors(store.handleCapabilities))
+
 	// Handle the API endpoint for creating a short URL
-	mux.HandleFunc("/api/v1/shorten", store.handleShorten)
+	mux.HandleFunc("/api/v1/shorten", store.cors(store.limit(store.shortenLimiter, store.requireAPIKey(store.handleShorten))))
+
+	// Handle the API endpoint for creating many short URLs at once. It applies shortenLimiter
+	// itself, per entry rather than per request.
+	mux.HandleFunc("/api/v1/shorten/batch", store.cors(store.requireAPIKey(store.handleBatchShorten)))
+
+	// Handle the API endpoint for reading the stats of a short URL
+	mux.HandleFunc("/api/v1/stats/{key}", store.cors(store.handleStats))
+	mux.HandleFunc("/api/v1/stats/{key}/daily", store.cors(store.handleDailyClicks))
+	mux.HandleFunc("/api/v1/stats/{key}/referrers", store.cors(store.handleReferrers))
+
+	// Handle the admin endpoint for paging through all short URLs
+	mux.HandleFunc("/api/v1/urls", store.cors(store.requireAdmin(store.handleListURLs)))
+
+	// Handle the admin endpoint for changing a short URL's destination or disabling it
+	mux.HandleFunc("/api/v1/urls/{key}", store.cors(store.requireAdmin(store.handleUpdateURL)))
+
+	// Handle the internal endpoint for looking up a short URL by its database ID
+	mux.HandleFunc("/api/v1/urls/by-id/{id}", store.cors(store.requireAdmin(store.handleStatsByID)))
+
+	// Handle the API endpoint for expanding a short URL without redirecting
+	mux.HandleFunc("/api/v1/expand", store.cors(store.handleExpand))
+	mux.HandleFunc("/api/v1/expand/{shortKey}", store.cors(store.handleExpand))
+
+	// Handle the API endpoint for a short URL's QR code
+	mux.HandleFunc("/api/v1/qr/{shortKey}", store.cors(store.handleQRCode))
+
+	// Handle the API endpoint for bots unfurling a short URL
+	mux.HandleFunc("/api/v1/unfurl/{shortKey}", store.cors(store.handleUnfurl))
+
+	// Handle the API endpoint for previewing the key a URL would get
+	mux.HandleFunc("/api/v1/preview-key", store.cors(store.handlePreviewKey))
+
+	// Handle the admin endpoint for approving a pending short URL
+	mux.HandleFunc("/api/v1/admin/approve/{shortKey}", store.cors(store.requireAdmin(store.handleApprove)))
+
+	// Handle the admin endpoint for inspecting the key generation settings
+	mux.HandleFunc("/api/v1/admin/keygen-config", store.cors(store.requireAdmin(store.handleKeygenConfig)))
 
 	// Handle the API endpoint for redirecting to the long URL
 	mux.HandleFunc("/", store.handleRedirect)
 
+	// Shed requests that can't finish in time instead of letting clients wait them out
+	requestBudget := getEnvDuration("REQUEST_BUDGET", 0)
+	shortener.MinQueryBudget = getEnvDuration("MIN_QUERY_BUDGET", shortener.MinQueryBudget)
+	shortener.QueryTimeout = getEnvDuration("DB_QUERY_TIMEOUT", 0)
+
+	shutdownTimeout := getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	shutdownDelay := getEnvDuration("SHUTDOWN_DELAY", 0)
+
+	// Optionally export traces of requests and database calls over OTLP
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		fatal("Could not set up tracing", "error", err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
 	serverAddr := fmt.Sprintf(":%s", port)
-	log.Printf("Starting server on %s", serverAddr)
-	log.Fatal(http.ListenAndServe(serverAddr, mux))
+	server := &http.Server{
+		Addr:    serverAddr,
+		Handler: withTracing(withRequestLogging(withRecovery(withTimeBudget(requestBudget, mux)))),
+	}
+
+	// Stop accepting new requests on SIGINT/SIGTERM and let in-flight ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	// Optionally delete expired links in the background until shutdown starts
+	purgeDone := make(chan struct{})
+	if interval := getEnvDuration("PURGE_EXPIRED_INTERVAL", 0); interval > 0 {
+		grace := getEnvDuration("PURGE_EXPIRED_GRACE", 0)
+		batchSize := getEnvInt("PURGE_BATCH_SIZE", shortener.DefaultPurgeBatchSize)
+		if batchSize <= 0 {
+			fatal("PURGE_BATCH_SIZE must be positive", "value", batchSize)
+		}
+		go func() {
+			defer close(purgeDone)
+			shortener.RunExpiredPurge(ctx, db, interval, grace, batchSize)
+		}()
+		slog.Info("Purging expired links", "interval", interval.String(), "grace", grace.String())
+	} else {
+		close(purgeDone)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", serverAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed", "error", err)
+		}
+	case <-ctx.Done():
+		stop()
+		store.shuttingDown.Store(true)
+		// Give the load balancer time to see the failing readiness probe before we stop listening
+		if shutdownDelay > 0 {
+			slog.Info("Shutdown signal received, waiting for traffic to drain away", "delay", shutdownDelay.String())
+			time.Sleep(shutdownDelay)
+		}
+		slog.Info("Shutting down, draining in-flight requests", "timeout", shutdownTimeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server did not shut down cleanly", "error", err)
+	} else {
+		slog.Info("All in-flight requests completed")
+	}
+
+	// Write buffered click events while the database is still open
+	if clickEvents != nil {
+		if err := clickEvents.Stop(shutdownCtx); err != nil {
+			slog.Error("Buffered click events were not fully written", "error", err)
+		} else {
+			slog.Info("Buffered click events written")
+		}
+	}
+
+	// Flush buffered clicks while the database is still open
+	if clicks != nil {
+		if err := clicks.Stop(shutdownCtx); err != nil {
+			slog.Error("Buffered clicks were not fully flushed", "error", err)
+		} else {
+			slog.Info("Buffered clicks flushed")
+		}
+	}
+
+	// The purge stops as soon as ctx is cancelled, wait for its last statement
+	select {
+	case <-purgeDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Expired link purge did not stop in time")
+	}
+
+	// Send the spans still buffered
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Buffered spans were not fully exported", "error", err)
+	}
+
+	// Only close the pool once no handler can still be using it
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	} else {
+		slog.Info("Database connections closed")
+	}
+	slog.Info("Server stopped")
 }
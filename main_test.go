@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+func TestShortenReportsAllValidationErrors(t *testing.T) {
+	s, _ := newTestStore()
+	body := `{"long_url": "ftp://10.0.0.1/files"}`
+
+	w, resp := postShorten(t, s, body, nil)
+	if w.Code != http.StatusBadRequest || len(resp.Errors) != 0 {
+		t.Errorf("default = %d with errors %q, want 400 with only the first error", w.Code, resp.Errors)
+	}
+
+	s.reportAllValidationErrors = true
+	w, resp = postShorten(t, s, body, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	if len(resp.Errors) != 2 {
+		t.Errorf("errors = %q, want the scheme and private host errors", resp.Errors)
+	}
+}
+
+func TestShortenReportsNormalizedURL(t *testing.T) {
+	s, _ := newTestStore()
+	const submitted = "HTTPS://Example.COM:443/Page"
+
+	if _, resp := postShorten(t, s, `{"long_url": "`+submitted+`"}`, nil); resp.OriginalURL != "" || resp.NormalizedURL != "" {
+		t.Errorf("default = %q, %q, want both forms left out", resp.OriginalURL, resp.NormalizedURL)
+	}
+
+	s.reportNormalizedURL = true
+	w, resp := postShorten(t, s, `{"long_url": "`+submitted+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 for the existing link", w.Code)
+	}
+	if resp.OriginalURL != submitted || resp.NormalizedURL != "https://example.com/Page" {
+		t.Errorf("original, normalized = %q, %q, want %q and the normalized form", resp.OriginalURL, resp.NormalizedURL, submitted)
+	}
+
+	// Nothing to report when the URL was already normal
+	if _, resp := postShorten(t, s, `{"long_url": "https://example.com/Page"}`, nil); resp.OriginalURL != "" || resp.NormalizedURL != "" {
+		t.Errorf("unchanged URL = %q, %q, want both forms left out", resp.OriginalURL, resp.NormalizedURL)
+	}
+}
+
+func TestShortenCapacityReached(t *testing.T) {
+	old := shortener.CreationCap
+	shortener.CreationCap = shortener.NewCreationLimit(1, 0)
+	t.Cleanup(func() { shortener.CreationCap = old })
+	s, _ := newTestStore()
+
+	if w, _ := postShorten(t, s, `{"long_url": "https://example.com/a"}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("first link status = %d, want 201", w.Code)
+	}
+	w, resp := postShorten(t, s, `{"long_url": "https://example.com/b"}`, nil)
+	if w.Code != http.StatusServiceUnavailable || resp.Error == "" {
+		t.Errorf("capped link = %d %q, want 503 with a message", w.Code, resp.Error)
+	}
+}
+
+func TestStatsByIDRejectsInvalidIDs(t *testing.T) {
+	s, _ := newTestStore()
+	for _, id := range []string{"abc", "0", "-3", "99999999999999999999"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/urls/by-id/"+id, nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.handleStatsByID(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("id %q: status = %d, want 400", id, w.Code)
+		}
+	}
+}
+
+func TestUnfurlDoesNotCountClick(t *testing.T) {
+	s, repo := newTestStore()
+	key := createLink(t, repo, "https://example.com/article?id=1", shortener.LinkOptions{})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/unfurl/"+key, nil)
+	r.SetPathValue("shortKey", key)
+	w := httptest.NewRecorder()
+	s.handleUnfurl(w, r)
+
+	var resp UnfurlResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusOK || resp.LongURL != "https://example.com/article?id=1" || resp.Host != "example.com" {
+		t.Errorf("unfurl = %d %+v", w.Code, resp)
+	}
+	if clicks, _ := repo.ClickCount(key); clicks != 1 {
+		t.Errorf("click count = %d, want 1 (unchanged from creation)", clicks)
+	}
+}
+
+func TestKeygenConfigMasksPepper(t *testing.T) {
+	const secret = "pepper-0123456789abcdef"
+	old := shortener.KeySecret
+	shortener.KeySecret = []byte(secret)
+	t.Cleanup(func() { shortener.KeySecret = old })
+	s, _ := newTestStore()
+	s.adminAPIKey = "admin-token"
+	handler := s.requireAdmin(s.handleKeygenConfig)
+
+	tests := []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer admin-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/keygen-config", nil)
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.status {
+			t.Errorf("Authorization %q: status = %d, want %d", tt.authorization, w.Code, tt.status)
+		}
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("Authorization %q: response reveals the pepper", tt.authorization)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var config shortener.KeygenConfig
+		if err := json.Unmarshal(w.Body.Bytes(), &config); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body.String(), err)
+		}
+		want := shortener.KeygenConfig{
+			Strategy:         "hash",
+			KeyLength:        shortener.KeyLength,
+			Alphabet:         "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+			HashAlgorithm:    "hmac-sha256",
+			PepperConfigured: true,
+			MaxRetries:       shortener.MaxRetries,
+		}
+		if config != want {
+			t.Errorf("config = %+v, want %+v", config, want)
+		}
+	}
+
+	s.adminAPIKey = ""
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/keygen-config", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status with the admin API disabled = %d, want 403", w.Code)
+	}
+}
+
+func TestExpiredLinkRedirects(t *testing.T) {
+	s, repo := newTestStore()
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	repo.Save(ctx, "expired", "https://example.com/sale", shortener.StatusActive, shortener.LinkOptions{ExpiresAt: &past})
+	repo.Save(ctx, "replace", "https://example.com/sale", shortener.StatusActive,
+		shortener.LinkOptions{ExpiresAt: &past, ExpiredRedirect: "https://example.com/sale-over"})
+	repo.Save(ctx, "current", "https://example.com/sale", shortener.StatusActive,
+		shortener.LinkOptions{ExpiresAt: &future, ExpiredRedirect: "https://example.com/sale-over"})
+
+	tests := []struct {
+		key      string
+		status   int
+		location string
+	}{
+		{"expired", http.StatusGone, ""},
+		{"replace", http.StatusFound, "https://example.com/sale-over"},
+		{"current", http.StatusFound, "https://example.com/sale"},
+	}
+	for _, tt := range tests {
+		w := followLink(s, http.MethodGet, "/"+tt.key, browserUserAgent)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: %d to %q, want %d to %q", tt.key, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+	if clicks, _ := repo.ClickCount("replace"); clicks != 1 {
+		t.Errorf("expired link click count = %d, want 1 (not counted)", clicks)
+	}
+}
+
+func TestUsedUpLinkFollowsReplacement(t *testing.T) {
+	s, repo := newTestStore()
+	repo.Save(context.Background(), "limited", "https://example.com/once", shortener.StatusActive,
+		shortener.LinkOptions{MaxClicks: 1, ExpiredRedirect: "https://example.com/gone"})
+
+	if w := followLink(s, http.MethodGet, "/limited", browserUserAgent); w.Header().Get("Location") != "https://example.com/once" {
+		t.Errorf("first visit went to %q, want the destination", w.Header().Get("Location"))
+	}
+	if w := followLink(s, http.MethodGet, "/limited", browserUserAgent); w.Header().Get("Location") != "https://example.com/gone" {
+		t.Errorf("second visit went to %q, want the replacement", w.Header().Get("Location"))
+	}
+}
+
+func TestHeadRedirectDoesNotCountClick(t *testing.T) {
+	s, repo := newTestStore()
+	key := createLink(t, repo, "https://example.com/", shortener.LinkOptions{})
+
+	w := followLink(s, http.MethodHead, "/"+key, browserUserAgent)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/" {
+		t.Errorf("HEAD = %d to %q, want a 302 to the destination", w.Code, w.Header().Get("Location"))
+	}
+	if clicks, _ := repo.ClickCount(key); clicks != 1 {
+		t.Errorf("click count after HEAD = %d, want 1 (unchanged)", clicks)
+	}
+
+	if w := followLink(s, http.MethodPost, "/"+key, browserUserAgent); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST = %d with Allow %q, want 405 allowing GET, HEAD", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		longURL, params, want string
+	}{
+		{"https://example.com/a", "", "https://example.com/a"},
+		{"https://example.com/a", "utm_source=mail", "https://example.com/a?utm_source=mail"},
+		{"https://example.com/a?id=7", "utm_source=mail", "https://example.com/a?id=7&utm_source=mail"},
+		// The stored URL's parameters win
+		{"https://example.com/a?ref=owner", "ref=visitor", "https://example.com/a?ref=owner"},
+		{"https://example.com/a?ref=owner", "ref=visitor&x=1", "https://example.com/a?ref=owner&x=1"},
+		// The stored query is left byte for byte, and the fragment stays last
+		{"https://example.com/a?b=2&a=1;c", "z=9", "https://example.com/a?b=2&a=1;c&z=9"},
+		{"https://example.com/a#top", "x=1", "https://example.com/a?x=1#top"},
+		{"https://example.com/a", "q=a+b&q=c%26d", "https://example.com/a?q=a+b&q=c%26d"},
+	}
+	for _, tt := range tests {
+		params, err := url.ParseQuery(tt.params)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.params, err)
+		}
+		if got := mergeQuery(tt.longURL, params); got != tt.want {
+			t.Errorf("mergeQuery(%q, %q) = %q, want %q", tt.longURL, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectForwardsQuery(t *testing.T) {
+	s, repo := newTestStore()
+	key := createLink(t, repo, "https://example.com/page?id=7", shortener.LinkOptions{})
+
+	w := followLink(s, http.MethodGet, "/"+key+"?utm_campaign=spring&id=8", browserUserAgent)
+	if got := w.Header().Get("Location"); got != "https://example.com/page?id=7&utm_campaign=spring" {
+		t.Errorf("Location = %q, want the stored query plus the new parameter", got)
+	}
+}
+
+func TestIsReservedPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api", true},
+		{"/api/", true},
+		{"/api/v2/unknown", true},
+		{"/metrics", true},
+		{"/healthz/extra", true},
+		{"/favicon.ico", true},
+		{"/", false},
+		{"/abc123", false},
+		{"/apikey", false},
+		{"/metricsX", false},
+	}
+	for _, tt := range tests {
+		if got := isReservedPath(tt.path); got != tt.want {
+			t.Errorf("isReservedPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReservedPathsAreNotShortKeys(t *testing.T) {
+	s, repo := newTestStore()
+	ctx := context.Background()
+	// Links that predate a route being reserved must not shadow it
+	for _, key := range []string{"metrics", "healthz", "apikey1"} {
+		repo.Save(ctx, key, "https://example.com/"+key, shortener.StatusActive, shortener.LinkOptions{})
+	}
+
+	for _, path := range []string{"/api", "/api/v2/unknown", "/metrics", "/healthz"} {
+		if w := followLink(s, http.MethodGet, path, browserUserAgent); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d to %q, want 404", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if clicks, _ := repo.ClickCount("metrics"); clicks != 1 {
+		t.Errorf("reserved key click count = %d, want 1 (never looked up)", clicks)
+	}
+	if w := followLink(s, http.MethodGet, "/apikey1", browserUserAgent); w.Code != http.StatusFound {
+		t.Errorf("GET /apikey1 = %d, want a redirect, only whole segments are reserved", w.Code)
+	}
+}
+
+func TestDisabledLinkStatus(t *testing.T) {
+	for _, status := range []int{http.StatusGone, http.StatusNotFound} {
+		s, repo := newTestStore()
+		s.disabledStatus = status
+		key := createLink(t, repo, "https://example.com/", shortener.LinkOptions{})
+		repo.SetActive(key, false)
+
+		if w := followLink(s, http.MethodGet, "/"+key, browserUserAgent); w.Code != status || w.Header().Get("Location") != "" {
+			t.Errorf("disabled link with status %d = %d to %q, want %d", status, w.Code, w.Header().Get("Location"), status)
+		}
+		if clicks, _ := repo.ClickCount(key); clicks != 1 {
+			t.Errorf("disabled link click count = %d, want 1 (not counted)", clicks)
+		}
+	}
+}
+
+func TestCorruptedLinkIsNotRedirected(t *testing.T) {
+	s, repo := newTestStore()
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	// Rows written outside the API skip validation
+	tests := []struct {
+		key     string
+		longURL string
+		opts    shortener.LinkOptions
+	}{
+		{"brokena", "/local/path", shortener.LinkOptions{}},
+		{"brokenb", "//evil.com/phish", shortener.LinkOptions{}},
+		{"brokenc", "evil.com/phish", shortener.LinkOptions{}},
+		{"brokend", "javascript:alert(1)", shortener.LinkOptions{}},
+		{"brokene", "https://example.com/sale", shortener.LinkOptions{ExpiresAt: &past, ExpiredRedirect: "evil.com/phish"}},
+	}
+	for _, tt := range tests {
+		repo.Save(ctx, tt.key, tt.longURL, shortener.StatusActive, tt.opts)
+
+		w := followLink(s, http.MethodGet, "/"+tt.key, browserUserAgent)
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Location") != "" {
+			t.Errorf("%s: %d to %q, want a 500 without a redirect", tt.key, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestIsAbsoluteHTTPURL(t *testing.T) {
+	tests := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"https://example.com/", true},
+		{"HTTP://example.com/path?q=1", true},
+		{"/relative", false},
+		{"//example.com/", false},
+		{"example.com", false},
+		{"ftp://example.com/", false},
+		{"https:///path", false},
+		{"http://%zz", false},
+	}
+	for _, tt := range tests {
+		if got := isAbsoluteHTTPURL(tt.rawURL); got != tt.want {
+			t.Errorf("isAbsoluteHTTPURL(%q) = %v, want %v", tt.rawURL, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidShortKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"abc123", true},
+		{"A-b_9", true},
+		{"", false},
+		{"abc/123", false},
+		{"abc 123", false},
+		{"abc%20", false},
+	}
+	for _, tt := range tests {
+		if got := isValidShortKey(tt.key); got != tt.want {
+			t.Errorf("isValidShortKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestExpandRejectsEmptyKey(t *testing.T) {
+	s, _ := newTestStore()
+	w := httptest.NewRecorder()
+	s.handleExpand(w, httptest.NewRequest(http.MethodGet, "/api/v1/expand", nil))
+
+	var resp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusBadRequest || resp.Error != "invalid short key format" {
+		t.Errorf("expand without a key = %d %q, want 400 invalid short key format", w.Code, resp.Error)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the correlation ID for a request, both inbound and outbound.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds incoming request IDs so clients can't bloat our logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// requestIDFromContext returns the request ID assigned by withRequestLogging, or "" if there is none.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger returns the logger for the request carrying ctx, which tags every line with
+// the request ID. It falls back to the default logger outside of a request.
+func requestLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// isValidRequestID reports whether an incoming request ID is safe to reuse. Only IDs made
+// of letters, digits, and the separators commonly used by tracing systems are accepted.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, char := range id {
+		if !((char >= 'A' && char <= 'Z') ||
+			(char >= 'a' && char <= 'z') ||
+			(char >= '0' && char <= '9') ||
+			char == '-' || char == '_' || char == '.' || char == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 128-bit request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder captures the status code written by a handler so it can be logged.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// withRequestLogging assigns every request an ID, echoes it in the X-Request-ID response
+// header, and logs the request once it completes. Handlers log through requestLogger so
+// their lines carry the same ID, and the trace ID when the request is traced (see
+// withTracing). A well-formed X-Request-ID sent by the client or an upstream proxy is
+// reused so logs can be correlated across services; a new ID is generated only when none
+// (or a malformed one) was provided.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		// Tag lines with the caller's trace so they can be found from a distributed trace
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+			logger = logger.With("trace_id", spanContext.TraceID().String())
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, loggerKey{}, logger)
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		logger.Info("request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// withRecovery turns a panic in a handler into a logged stack trace and a 500 JSON response,
+// instead of a dropped connection. It sits inside withRequestLogging so the panic is logged
+// with the request ID and the request is logged with its 500. http.ErrAbortHandler, which
+// handlers panic with to abort a response on purpose, is passed through.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			requestLogger(r.Context()).Error("panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()))
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withTimeBudget gives every request a deadline of budget from its arrival. The shortener
+// checks the remaining time before each database call and fails fast with a 503 when too
+// little is left, so slow requests are shed early rather than timing out. A zero budget
+// disables the deadline.
+func withTimeBudget(budget time.Duration, next http.Handler) http.Handler {
+	if budget <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
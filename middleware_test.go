@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+func TestRequestIDPassthrough(t *testing.T) {
+	logs := captureLogs(t)
+	var handlerID string
+	handler := withRequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerID = requestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name, sent string
+		reused     bool
+	}{
+		{"well-formed", "req-42.a_b:c", true},
+		{"missing", "", false},
+		{"invalid characters", "id with spaces", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/health", nil)
+		if tt.sent != "" {
+			r.Header.Set(requestIDHeader, tt.sent)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		echoed := w.Header().Get(requestIDHeader)
+		if tt.reused && echoed != tt.sent {
+			t.Errorf("%s: echoed %q, want the sent ID", tt.name, echoed)
+		}
+		if !tt.reused && (echoed == tt.sent || len(echoed) != 32) {
+			t.Errorf("%s: echoed %q, want a newly generated ID", tt.name, echoed)
+		}
+		if handlerID != echoed {
+			t.Errorf("%s: handler saw %q, want the echoed %q", tt.name, handlerID, echoed)
+		}
+
+		var line struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &line); err != nil || line.RequestID != echoed {
+			t.Errorf("%s: logged request_id %q (%v), want %q", tt.name, line.RequestID, err, echoed)
+		}
+	}
+}
+
+// slowRepository is a Repository whose URL lookups take delay, like a database under load.
+type slowRepository struct {
+	*shortener.MemoryRepository
+	delay time.Duration
+}
+
+func (r slowRepository) FindByLongURL(ctx context.Context, longURL string) (string, error) {
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return r.MemoryRepository.FindByLongURL(ctx, longURL)
+}
+
+func TestTimeBudgetShedsSlowRequests(t *testing.T) {
+	repo := slowRepository{MemoryRepository: shortener.NewMemoryRepository(), delay: 70 * time.Millisecond}
+	s, _ := newTestStore()
+	s.repo = repo
+	body := `{"long_url": "https://example.com/slow"}`
+
+	// With 100ms to spend, the lookup leaves less than MinQueryBudget for the insert
+	handler := withTimeBudget(100*time.Millisecond, http.HandlerFunc(s.handleShorten))
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(body)))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %v, want it to fail fast", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if key, _ := repo.MemoryRepository.FindByLongURL(context.Background(), "https://example.com/slow"); key != "" {
+		t.Error("the link was created after the budget ran out")
+	}
+
+	// Without a budget the same request is simply slow
+	w = httptest.NewRecorder()
+	withTimeBudget(0, http.HandlerFunc(s.handleShorten)).ServeHTTP(w,
+		httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("status without a budget = %d, want 201", w.Code)
+	}
+}
+
+func TestRecoveryReturnsJSON500(t *testing.T) {
+	logs := captureLogs(t)
+	handler := withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var store *Store
+		_ = store.repo // nil pointer dereference
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusInternalServerError || resp.Error == "" {
+		t.Errorf("panicking handler = %d %q, want a 500 JSON error", w.Code, w.Body.String())
+	}
+	var entry struct {
+		Msg   string `json:"msg"`
+		Path  string `json:"path"`
+		Stack string `json:"stack"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil || entry.Path != "/boom" || !strings.Contains(entry.Stack, "goroutine") {
+		t.Errorf("panic log = %q, want the path and stack trace", logs.String())
+	}
+}
+
+func TestRecoveryRepanicsAbortHandler(t *testing.T) {
+	handler := withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		// net/http aborts the response silently for this panic, so it must get through
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler passed through", rec)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
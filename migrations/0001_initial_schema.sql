@@ -0,0 +1,56 @@
+-- Baseline schema. Everything is IF NOT EXISTS, and the urls table is brought up to date
+-- with ALTER TABLE, so databases that were set up by hand before migrations existed (with
+-- the original urls table of id, short_key VARCHAR(7), long_url, created_at, and
+-- click_count) can adopt them without errors.
+
+CREATE TABLE IF NOT EXISTS urls (
+    id SERIAL PRIMARY KEY,
+    -- Sized for the largest configurable KEY_LENGTH
+    short_key VARCHAR(43) UNIQUE NOT NULL,
+    long_url TEXT NOT NULL,
+    -- PostgreSQL (timezone-aware)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    click_count INTEGER DEFAULT 1,
+    -- 'active' links resolve, 'pending' links wait for admin approval
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    -- Links stop resolving (410 Gone) once this passes; NULL never expires
+    expires_at TIMESTAMPTZ,
+    -- Where an expired link sends visitors instead of returning 410 Gone
+    expired_redirect TEXT,
+    -- Name of the API key that created the link, NULL when API keys are disabled
+    created_by VARCHAR(64)
+);
+
+-- Columns added after the original hand-made table; no-ops on tables created above
+ALTER TABLE urls ALTER COLUMN short_key TYPE VARCHAR(43);
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expired_redirect TEXT;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_by VARCHAR(64);
+
+-- Index for fast lookups by short_key (your redirect endpoint)
+CREATE INDEX IF NOT EXISTS idx_short_key ON urls(short_key);
+
+-- Index for checking if long_url exists (deduplication)
+CREATE INDEX IF NOT EXISTS idx_long_url ON urls(long_url);
+
+-- Source of keys for KEY_STRATEGY=sequential
+CREATE SEQUENCE IF NOT EXISTS short_key_seq;
+
+-- Per-day click totals, maintained when CLICK_TRACKING=daily
+CREATE TABLE IF NOT EXISTS url_clicks_daily (
+    short_key VARCHAR(43) NOT NULL,
+    day DATE NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_key, day)
+);
+
+-- Client API keys for link creation, checked when API_KEYS_DB=true
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) UNIQUE NOT NULL,
+    -- Hex SHA-256 of the key; raw keys are never stored
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
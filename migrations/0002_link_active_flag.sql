@@ -0,0 +1,3 @@
+-- Lets admins take a link offline (e.g. after an abuse report) without deleting it, so its
+-- analytics are kept. Inactive links don't resolve.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
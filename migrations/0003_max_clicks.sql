@@ -0,0 +1,3 @@
+-- Links stop resolving once click_count reaches max_clicks; NULL for unlimited. Limited
+-- links are inserted with click_count 0 so the limit counts real visits.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks INTEGER;
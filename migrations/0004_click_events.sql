@@ -0,0 +1,11 @@
+-- One row per counted redirect with where it came from, written in batches when
+-- CLICK_EVENTS is enabled. referrer is NULL for direct visits.
+CREATE TABLE IF NOT EXISTS click_events (
+    id BIGSERIAL PRIMARY KEY,
+    short_key VARCHAR(43) NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    referrer TEXT,
+    user_agent TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_click_events_short_key ON click_events (short_key, clicked_at);
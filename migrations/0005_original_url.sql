@@ -0,0 +1,4 @@
+-- The URL as its first creator submitted it, when KEEP_ORIGINAL_URL is enabled and
+-- normalization changed it. long_url keeps the normalized form for dedup; redirects go to
+-- original_url when it is set.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS original_url TEXT;
@@ -0,0 +1,115 @@
+// Package migrations embeds the database schema as ordered SQL files and applies the ones a
+// database hasn't seen yet. Files are named NNNN_description.sql and run in name order; each
+// runs in its own transaction and is recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// advisoryLockID serializes migration runs across instances starting at the same time.
+const advisoryLockID = 7470417
+
+// Run applies every embedded migration that isn't yet recorded in schema_migrations, in
+// order, and returns the versions it applied. It stops at the first failing migration,
+// leaving that migration rolled back and the ones before it applied.
+func Run(ctx context.Context, db *sql.DB) ([]string, error) {
+	// Advisory locks belong to a session, so hold one connection for the whole run
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		return nil, fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+
+	query := `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version TEXT PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )
+    `
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var ran []string
+	for _, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+		if applied[version] {
+			continue
+		}
+		if err := apply(ctx, conn, name, version); err != nil {
+			return ran, err
+		}
+		ran = append(ran, version)
+	}
+	return ran, nil
+}
+
+// appliedVersions returns the set of versions recorded in schema_migrations.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// apply runs one migration file and records it, in a single transaction.
+func apply(ctx context.Context, conn *sql.Conn, name, version string) error {
+	contents, err := files.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(contents)); err != nil {
+		return fmt.Errorf("migration %s failed: %w", version, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return fmt.Errorf("migration %s: %w", version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %s: %w", version, err)
+	}
+	return nil
+}
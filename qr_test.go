@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+// getQRCode requests the QR code for key through the mux pattern handleQRCode is served on.
+func getQRCode(s *Store, key, query string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/qr/{shortKey}", s.handleQRCode)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/qr/"+key+query, nil))
+	return w
+}
+
+func TestQRCodeImage(t *testing.T) {
+	s, repo := newTestStore()
+	repo.Save(context.Background(), "abcdefg", "https://example.com/", shortener.StatusActive, shortener.LinkOptions{})
+
+	for _, tt := range []struct {
+		query string
+		size  int
+	}{
+		{"", defaultQRSize},
+		{"?size=64", minQRSize},
+		{"?size=300", 300},
+		{"?size=1024", maxQRSize},
+	} {
+		w := getQRCode(s, "abcdefg", tt.query)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("QR code%s = %d %s, want a PNG", tt.query, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Errorf("QR code%s isn't a valid PNG: %v", tt.query, err)
+			continue
+		}
+		if bounds := img.Bounds(); bounds.Dx() != tt.size || bounds.Dy() != tt.size {
+			t.Errorf("QR code%s is %dx%d, want %dx%d", tt.query, bounds.Dx(), bounds.Dy(), tt.size, tt.size)
+		}
+	}
+}
+
+func TestQRCodeErrors(t *testing.T) {
+	s, repo := newTestStore()
+	repo.Save(context.Background(), "abcdefg", "https://example.com/", shortener.StatusActive, shortener.LinkOptions{})
+
+	tests := []struct {
+		key, query string
+		status     int
+	}{
+		{"abcdefg", "?size=63", http.StatusBadRequest},
+		{"abcdefg", "?size=1025", http.StatusBadRequest},
+		{"abcdefg", "?size=big", http.StatusBadRequest},
+		{"abc.efg", "", http.StatusBadRequest},
+		{"AAAAAAA", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := getQRCode(s, tt.key, tt.query); w.Code != tt.status {
+			t.Errorf("QR code for %s%s = %d, want %d", tt.key, tt.query, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// staleLimiterSweepInterval is how often idle limiters are dropped from a rateLimiter.
+const staleLimiterSweepInterval = time.Minute
+
+// rateLimiter is a token-bucket limiter keyed by client, with one rate.Limiter per client.
+// Each client's bucket holds up to burst tokens and refills at rate tokens per second; a
+// request spends one token. State is in memory and per instance.
+type rateLimiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	limiters  map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:      rps,
+		burst:     burst,
+		limiters:  make(map[string]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// allowN spends n tokens from key's bucket. When there aren't enough it spends none and
+// returns false and how long until there will be. A request for more than burst tokens can
+// never be allowed and returns false with a zero wait.
+func (l *rateLimiter) allowN(key string, n int) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	l.sweep(now)
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.rate), l.burst)
+		l.limiters[key] = limiter
+	}
+	l.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, n)
+	if !reservation.OK() {
+		return false, 0
+	}
+	if wait := reservation.DelayFrom(now); wait > 0 {
+		reservation.CancelAt(now)
+		return false, wait
+	}
+	return true, 0
+}
+
+// sweep drops limiters that have refilled completely, since they are indistinguishable
+// from new ones. The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < staleLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, limiter := range l.limiters {
+		if limiter.TokensAt(now) >= float64(l.burst) {
+			delete(l.limiters, key)
+		}
+	}
+}
+
+// limit wraps a handler so each client is limited independently, one token per request.
+// Rejected requests get a 429 with a Retry-After header in whole seconds.
+func (s *Store) limit(l *rateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allowN(s.clientIP(r), 1); !ok {
+			writeRateLimited(w, wait)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeRateLimited answers a request rejected by a rateLimiter, with a Retry-After of wait
+// rounded up to whole seconds.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "rate limit exceeded"})
+}
+
+// clientIP returns the address of the client that sent r. When the service runs behind a
+// trusted reverse proxy, the last X-Forwarded-For entry (the one the proxy appended) is
+// used; the header is ignored otherwise, since clients can set it to anything.
+func (s *Store) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
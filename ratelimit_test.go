@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowN(t *testing.T) {
+	// Slow enough that nothing refills during the test
+	l := newRateLimiter(0.001, 3)
+
+	if ok, _ := l.allowN("a", 2); !ok {
+		t.Fatal("first 2 tokens refused, want the burst available")
+	}
+	ok, wait := l.allowN("a", 2)
+	if ok || wait <= 0 {
+		t.Errorf("allowN(2) with 1 token left = %v, %v, want refused with a wait", ok, wait)
+	}
+	// The refused request spent nothing
+	if ok, _ := l.allowN("a", 1); !ok {
+		t.Error("last token refused after a rejected request")
+	}
+	if ok, _ := l.allowN("a", 1); ok {
+		t.Error("allowed past the burst")
+	}
+
+	if ok, _ := l.allowN("b", 3); !ok {
+		t.Error("another client was refused, want clients limited independently")
+	}
+	if ok, wait := l.allowN("c", 4); ok || wait != 0 {
+		t.Errorf("allowN(more than burst) = %v, %v, want refused with no wait, it can never succeed", ok, wait)
+	}
+}
+
+func TestRateLimiterSweepsRefilledLimiters(t *testing.T) {
+	l := newRateLimiter(1000, 1)
+	l.allowN("idle", 1)
+	time.Sleep(5 * time.Millisecond)
+	l.lastSweep = time.Now().Add(-staleLimiterSweepInterval)
+	l.allowN("busy", 1)
+
+	if _, ok := l.limiters["idle"]; ok {
+		t.Error("refilled limiter kept after a sweep")
+	}
+	if _, ok := l.limiters["busy"]; !ok {
+		t.Error("limiter that just spent its token was swept")
+	}
+}
+
+func TestLimitMiddleware(t *testing.T) {
+	s, _ := newTestStore()
+	handler := s.limit(newRateLimiter(0.5, 1), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request("192.0.2.1:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("first request = %d, want it passed through", w.Code)
+	}
+	w := request("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request = %d, want 429", w.Code)
+	}
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 1 || retry > 2 {
+		t.Errorf("Retry-After = %q, want the 2s refill rounded up", w.Header().Get("Retry-After"))
+	}
+	if w := request("192.0.2.2:1234"); w.Code != http.StatusNoContent {
+		t.Errorf("request from another IP = %d, want it passed through", w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{false, "", "192.0.2.1"},
+		{false, "203.0.113.9", "192.0.2.1"},
+		{true, "", "192.0.2.1"},
+		{true, "203.0.113.9", "203.0.113.9"},
+		{true, "198.51.100.7, 203.0.113.9", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		s := &Store{trustProxy: tt.trustProxy}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := s.clientIP(r); got != tt.want {
+			t.Errorf("clientIP(trustProxy %v, X-Forwarded-For %q) = %q, want %q", tt.trustProxy, tt.forwarded, got, tt.want)
+		}
+	}
+}
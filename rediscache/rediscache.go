@@ -0,0 +1,89 @@
+// Package rediscache implements shortener.Cache on Redis, so instances share cached
+// redirects and a restart doesn't empty the cache.
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// keyPrefix namespaces our keys in a Redis instance that may be shared
+	keyPrefix = "shortener:url:"
+	// maxIdleConns is how many connections are kept open between commands
+	maxIdleConns = 8
+	// dialTimeout bounds connecting to Redis when the caller's context has no deadline
+	dialTimeout = 2 * time.Second
+	// DefaultTimeout is the suggested per-command timeout for New
+	DefaultTimeout = time.Second
+)
+
+// Client caches short key to long URL mappings in Redis. It is safe for concurrent use.
+type Client struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// New returns a client for the Redis server at redisURL, in the form
+// redis://[[user]:password@]host[:port][/db] or rediss:// for TLS. Cached entries expire after ttl.
+// Commands whose context has no deadline give up after timeout, so a stalled server slows
+// redirects down instead of hanging them. It connects once to verify the server is reachable.
+func New(ctx context.Context, redisURL string, ttl, timeout time.Duration) (*Client, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("redis cache TTL must be positive")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("redis timeout must be positive")
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	opts.DialTimeout = dialTimeout
+	opts.ReadTimeout = timeout
+	opts.WriteTimeout = timeout
+	// Use the caller's deadline when there is one, and timeout otherwise
+	opts.ContextTimeoutEnabled = true
+	opts.MaxIdleConns = maxIdleConns
+	// A cache miss costs one database query, less than waiting out retries against a
+	// struggling server
+	opts.MaxRetries = -1
+
+	c := &Client{rdb: redis.NewClient(opts), ttl: ttl}
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		c.rdb.Close()
+		return nil, fmt.Errorf("could not reach redis: %w", err)
+	}
+	return c, nil
+}
+
+// Get returns the cached long URL for shortKey, with ok false on a cache miss.
+func (c *Client) Get(ctx context.Context, shortKey string) (string, bool, error) {
+	longURL, err := c.rdb.Get(ctx, keyPrefix+shortKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return longURL, true, nil
+}
+
+// Set caches longURL for shortKey for the client's TTL.
+func (c *Client) Set(ctx context.Context, shortKey, longURL string) error {
+	return c.rdb.Set(ctx, keyPrefix+shortKey, longURL, c.ttl).Err()
+}
+
+// Delete removes any cached entry for shortKey.
+func (c *Client) Delete(ctx context.Context, shortKey string) error {
+	return c.rdb.Del(ctx, keyPrefix+shortKey).Err()
+}
+
+// Close closes the client's connections.
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
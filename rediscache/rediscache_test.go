@@ -0,0 +1,174 @@
+package rediscache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewRejectsNonPositiveDurations(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		ttl, timeout time.Duration
+	}{
+		{"zero ttl", 0, time.Second},
+		{"negative ttl", -time.Minute, time.Second},
+		{"zero timeout", time.Hour, 0},
+	} {
+		if _, err := New(context.Background(), "redis://127.0.0.1:1", tt.ttl, tt.timeout); err == nil {
+			t.Errorf("%s: New succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestCommandTimesOutWithoutContextDeadline(t *testing.T) {
+	// A server that accepts connections but never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			cn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer cn.Close()
+		}
+	}()
+
+	start := time.Now()
+	_, err = New(context.Background(), "redis://"+ln.Addr().String(), time.Hour, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("New succeeded against a stalled server, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("New took %v to give up, want about the 50ms timeout", elapsed)
+	}
+}
+
+// newTestClient returns a client for server with a one hour TTL.
+func newTestClient(t *testing.T, redisURL string) *Client {
+	t.Helper()
+	c, err := New(context.Background(), redisURL, time.Hour, time.Second)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestGetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	c := newTestClient(t, "redis://"+server.Addr())
+
+	if _, ok, err := c.Get(ctx, "abcdefg"); ok || err != nil {
+		t.Fatalf("Get before Set = %v, %v, want a miss", ok, err)
+	}
+	if err := c.Set(ctx, "abcdefg", "https://example.com/"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, ok, err := c.Get(ctx, "abcdefg"); !ok || err != nil || got != "https://example.com/" {
+		t.Errorf("Get = %q, %v, %v, want the cached URL", got, ok, err)
+	}
+	// Keys are namespaced and expire after the TTL
+	if got, _ := server.Get(keyPrefix + "abcdefg"); got != "https://example.com/" {
+		t.Errorf("stored under the prefixed key = %q", got)
+	}
+	if ttl := server.TTL(keyPrefix + "abcdefg"); ttl != time.Hour {
+		t.Errorf("TTL = %v, want 1h", ttl)
+	}
+
+	if err := c.Delete(ctx, "abcdefg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "abcdefg"); ok {
+		t.Error("Get after Delete hit")
+	}
+}
+
+func TestEntriesExpire(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	c := newTestClient(t, "redis://"+server.Addr())
+
+	c.Set(ctx, "abcdefg", "https://example.com/")
+	server.FastForward(time.Hour)
+	if _, ok, _ := c.Get(ctx, "abcdefg"); ok {
+		t.Error("entry still cached after its TTL")
+	}
+}
+
+func TestErrorReplies(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	c := newTestClient(t, "redis://"+server.Addr())
+
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if _, _, err := c.Get(ctx, "abcdefg"); err == nil || !strings.Contains(err.Error(), "LOADING") {
+		t.Errorf("Get during an error reply = %v, want the server's error", err)
+	}
+	if err := c.Set(ctx, "abcdefg", "https://example.com/"); err == nil {
+		t.Error("Set during an error reply succeeded")
+	}
+
+	// The connection stays usable once the server recovers
+	server.SetError("")
+	if err := c.Set(ctx, "abcdefg", "https://example.com/"); err != nil {
+		t.Errorf("Set after recovery: %v", err)
+	}
+}
+
+func TestReconnectsAfterDroppedConnection(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	c := newTestClient(t, "redis://"+server.Addr())
+	c.Set(ctx, "abcdefg", "https://example.com/")
+
+	server.Close()
+	if _, _, err := c.Get(ctx, "abcdefg"); err == nil {
+		t.Fatal("Get with the server down succeeded")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restarting server: %v", err)
+	}
+	if got, ok, err := c.Get(ctx, "abcdefg"); !ok || err != nil || got != "https://example.com/" {
+		t.Errorf("Get after the server came back = %q, %v, %v, want the cached URL", got, ok, err)
+	}
+}
+
+func TestAuthAndDatabase(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("cache", "secret")
+
+	if _, err := New(ctx, "redis://cache:wrong@"+server.Addr(), time.Hour, time.Second); err == nil {
+		t.Error("New with a wrong password succeeded")
+	}
+	if _, err := New(ctx, "redis://"+server.Addr(), time.Hour, time.Second); err == nil {
+		t.Error("New without credentials succeeded against a server requiring them")
+	}
+
+	c := newTestClient(t, "redis://cache:secret@"+server.Addr()+"/3")
+	if err := c.Set(ctx, "abcdefg", "https://example.com/"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	server.Select(3)
+	if got, _ := server.Get(keyPrefix + "abcdefg"); got != "https://example.com/" {
+		t.Errorf("database 3 holds %q, want the entry stored in the URL's database", got)
+	}
+}
+
+func TestNewRejectsInvalidURLs(t *testing.T) {
+	for _, redisURL := range []string{"http://localhost:6379", "redis://localhost:6379/notadb", "://"} {
+		if _, err := New(context.Background(), redisURL, time.Hour, time.Second); err == nil {
+			t.Errorf("New(%q) succeeded, want an error", redisURL)
+		}
+	}
+}
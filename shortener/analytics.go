@@ -0,0 +1,161 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// ClickTrackingOff only maintains the scalar click_count
+	ClickTrackingOff = "off"
+	// ClickTrackingDaily also maintains per-day totals in url_clicks_daily
+	ClickTrackingDaily = "daily"
+)
+
+// ClickTracking selects how clicks are recorded beyond the scalar click_count.
+var ClickTracking = ClickTrackingOff
+
+// SetClickTracking selects the click tracking mode, ClickTrackingOff or ClickTrackingDaily.
+func SetClickTracking(mode string) error {
+	switch mode {
+	case ClickTrackingOff, ClickTrackingDaily:
+		ClickTracking = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown click tracking mode %q, expected %q or %q", mode, ClickTrackingOff, ClickTrackingDaily)
+	}
+}
+
+// recordClick adds a click to the configured analytics after a successful redirect.
+// Analytics are best effort: failures are logged and never fail the redirect itself.
+func recordClick(ctx context.Context, db Querier, shortKey string) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if ClickTracking != ClickTrackingDaily {
+		return
+	}
+
+	// One row per link per UTC day, so storage grows with days rather than with clicks
+	query := `
+        INSERT INTO url_clicks_daily (short_key, day, clicks)
+        VALUES ($1, (now() AT TIME ZONE 'UTC')::date, 1)
+        ON CONFLICT (short_key, day) DO UPDATE SET clicks = url_clicks_daily.clicks + 1
+    `
+	if _, err := db.ExecContext(ctx, query, shortKey); err != nil {
+		slog.ErrorContext(ctx, "failed to record daily click", "short_key", shortKey, "error", err)
+	}
+}
+
+// DailyClicks is the number of clicks a link received on one UTC day.
+type DailyClicks struct {
+	Day    string `json:"day"`
+	Clicks int64  `json:"clicks"`
+}
+
+// GetDailyClicks returns the per-day click totals for shortKey between from and to
+// (inclusive, UTC dates), oldest first. Days without clicks are omitted.
+//
+// Returns ErrNotFound if the short key doesn't exist, or a wrapped error if the query fails.
+func GetDailyClicks(ctx context.Context, db Querier, shortKey string, from, to time.Time) ([]DailyClicks, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM urls WHERE short_key = $1)`, shortKey).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	query := `
+        SELECT to_char(day, 'YYYY-MM-DD'), clicks
+        FROM url_clicks_daily
+        WHERE short_key = $1 AND day BETWEEN $2::date AND $3::date
+        ORDER BY day
+    `
+	rows, err := db.QueryContext(ctx, query, shortKey, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	series := []DailyClicks{}
+	for rows.Next() {
+		var day DailyClicks
+		if err := rows.Scan(&day.Day, &day.Clicks); err != nil {
+			return nil, fmt.Errorf("database query failed: %w", err)
+		}
+		series = append(series, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+
+	return series, nil
+}
+
+// ReferrerClicks is the number of recorded clicks that came from one referrer.
+type ReferrerClicks struct {
+	// Referrer is the Referer header sent with the clicks, empty for direct visits
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// GetTopReferrers returns up to limit referrers of shortKey's recorded click events, most
+// clicks first. Only clicks recorded while CLICK_EVENTS was enabled are included.
+//
+// Returns ErrNotFound if the short key doesn't exist, or a wrapped error if the query fails.
+func GetTopReferrers(ctx context.Context, db Querier, shortKey string, limit int) ([]ReferrerClicks, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM urls WHERE short_key = $1)`, shortKey).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	query := `
+        SELECT COALESCE(referrer, ''), COUNT(*) AS clicks
+        FROM click_events
+        WHERE short_key = $1
+        GROUP BY referrer
+        ORDER BY clicks DESC, referrer
+        LIMIT $2
+    `
+	rows, err := db.QueryContext(ctx, query, shortKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	referrers := []ReferrerClicks{}
+	for rows.Next() {
+		var referrer ReferrerClicks
+		if err := rows.Scan(&referrer.Referrer, &referrer.Clicks); err != nil {
+			return nil, fmt.Errorf("database query failed: %w", err)
+		}
+		referrers = append(referrers, referrer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+
+	return referrers, nil
+}
@@ -0,0 +1,97 @@
+package shortener
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useClickTracking sets ClickTracking for the rest of the test.
+func useClickTracking(t *testing.T, mode string) {
+	t.Helper()
+	old := ClickTracking
+	t.Cleanup(func() { ClickTracking = old })
+	if err := SetClickTracking(mode); err != nil {
+		t.Fatalf("SetClickTracking(%q): %v", mode, err)
+	}
+}
+
+func TestRedirectRecordsDailyClick(t *testing.T) {
+	for _, mode := range []string{ClickTrackingOff, ClickTrackingDaily} {
+		useClickTracking(t, mode)
+		var rollups []string
+		db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+			switch {
+			case strings.Contains(query, "url_clicks_daily"):
+				rollups = append(rollups, args[0].Value.(string))
+				if !strings.Contains(query, "ON CONFLICT (short_key, day) DO UPDATE SET clicks = url_clicks_daily.clicks + 1") {
+					t.Errorf("rollup statement doesn't add to the day's existing row:\n%s", query)
+				}
+				// Rollups are best effort and must not fail the redirect
+				return fakeResult{}, errors.New("relation does not exist")
+			case strings.Contains(query, "RETURNING "+redirectColumn):
+				return fakeResult{columns: []string{"long_url"}, rows: [][]driver.Value{{"https://example.com/"}}}, nil
+			}
+			return fakeResult{}, errors.New("unexpected statement: " + query)
+		})
+
+		longURL, err := HandleRedirectRequest(context.Background(), NewPostgresRepository(db), "abcdefg")
+		if err != nil || longURL != "https://example.com/" {
+			t.Errorf("%s: HandleRedirectRequest = %q, %v", mode, longURL, err)
+		}
+		want := []string(nil)
+		if mode == ClickTrackingDaily {
+			want = []string{"abcdefg"}
+		}
+		if !reflect.DeepEqual(rollups, want) {
+			t.Errorf("%s: rolled up clicks for %q, want %q", mode, rollups, want)
+		}
+	}
+}
+
+func TestSetClickTrackingRejectsUnknownModes(t *testing.T) {
+	useClickTracking(t, ClickTrackingOff)
+	if err := SetClickTracking("hourly"); err == nil {
+		t.Error("SetClickTracking(hourly) succeeded, want an error")
+	}
+	if ClickTracking != ClickTrackingOff {
+		t.Errorf("ClickTracking = %q after a rejected mode, want it unchanged", ClickTracking)
+	}
+}
+
+func TestGetDailyClicks(t *testing.T) {
+	var gotRange []any
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "SELECT EXISTS") {
+			return fakeResult{columns: []string{"exists"}, rows: [][]driver.Value{{args[0].Value == "abcdefg"}}}, nil
+		}
+		gotRange = []any{args[1].Value, args[2].Value}
+		return fakeResult{columns: []string{"day", "clicks"}, rows: [][]driver.Value{
+			{"2026-03-13", int64(4)},
+			{"2026-03-14", int64(1)},
+		}}, nil
+	})
+	ctx := context.Background()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	series, err := GetDailyClicks(ctx, db, "abcdefg", from, to)
+	if err != nil {
+		t.Fatalf("GetDailyClicks: %v", err)
+	}
+	want := []DailyClicks{{Day: "2026-03-13", Clicks: 4}, {Day: "2026-03-14", Clicks: 1}}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("series = %+v, want %+v", series, want)
+	}
+	if !reflect.DeepEqual(gotRange, []any{"2026-03-01", "2026-03-14"}) {
+		t.Errorf("queried days %v, want 2026-03-01 through 2026-03-14", gotRange)
+	}
+
+	if _, err := GetDailyClicks(ctx, db, "AAAAAAA", from, to); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDailyClicks(unknown) = %v, want ErrNotFound", err)
+	}
+}
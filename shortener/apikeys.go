@@ -0,0 +1,36 @@
+package shortener
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashAPIKey returns the hex SHA-256 digest under which an API key is stored in the
+// api_keys table. Raw keys are never stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupAPIKey returns the name of the active API key matching key in the api_keys table,
+// or ErrNotFound if there is none or it has been revoked. Keys are looked up by hash, so the
+// comparison doesn't leak timing about stored keys.
+func LookupAPIKey(ctx context.Context, db Querier, key string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var name string
+	query := `SELECT name FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	err := db.QueryRowContext(ctx, query, HashAPIKey(key)).Scan(&name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("database query failed: %w", err)
+	}
+	return name, nil
+}
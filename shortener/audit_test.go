@@ -0,0 +1,69 @@
+package shortener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// captureAudit routes audit records to the returned buffer for the rest of the test.
+func captureAudit(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var records bytes.Buffer
+	old := AuditLog
+	AuditLog = NewAuditLogger(&records)
+	t.Cleanup(func() { AuditLog = old })
+	return &records
+}
+
+func TestAuditCreateEventShape(t *testing.T) {
+	records := captureAudit(t)
+	repo := NewMemoryRepository()
+
+	result, err := HandleShortURLRequest(context.Background(), "https://example.com/", repo, LinkOptions{CreatedBy: "ci"})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(records.Bytes(), &record); err != nil {
+		t.Fatalf("decoding audit record %q: %v", records.String(), err)
+	}
+	if len(record) != 5 {
+		t.Errorf("record has fields %v, want exactly time, msg, event, short_key, and actor", record)
+	}
+	want := map[string]string{"msg": "audit", "event": AuditCreate, "short_key": result.ShortKey, "actor": "ci"}
+	for field, value := range want {
+		if record[field] != value {
+			t.Errorf("%s = %v, want %q", field, record[field], value)
+		}
+	}
+	if ts, _ := record["time"].(string); ts == "" {
+		t.Error("record has no time")
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("time %q is not RFC 3339: %v", ts, err)
+	}
+
+	// Returning the existing link changes nothing, so nothing is audited
+	records.Reset()
+	if _, err := HandleShortURLRequest(context.Background(), "https://example.com/", repo, LinkOptions{}); err != nil {
+		t.Fatalf("HandleShortURLRequest again: %v", err)
+	}
+	if records.Len() != 0 {
+		t.Errorf("deduplicated request was audited: %s", records.String())
+	}
+}
+
+func TestAuditAnonymousActor(t *testing.T) {
+	records := captureAudit(t)
+	audit(AuditDelete, "abcdefg", "")
+
+	var record struct {
+		Actor string `json:"actor"`
+	}
+	if err := json.Unmarshal(records.Bytes(), &record); err != nil || record.Actor != "anonymous" {
+		t.Errorf("actor = %q (%v), want anonymous", record.Actor, err)
+	}
+}
@@ -0,0 +1,37 @@
+package shortener
+
+import (
+	"context"
+	"time"
+)
+
+// MinQueryBudget is the least time a request must have left before its deadline for a
+// database call to be started. Requests with less remaining fail fast with
+// ErrBudgetExceeded instead of starting work that would likely time out anyway.
+var MinQueryBudget = 50 * time.Millisecond
+
+// checkBudget returns ErrBudgetExceeded when ctx is done or its deadline is closer than
+// MinQueryBudget. Contexts without a deadline always pass.
+func checkBudget(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ErrBudgetExceeded
+	}
+	deadline, ok := ctx.Deadline()
+	if ok && time.Until(deadline) < MinQueryBudget {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// QueryTimeout bounds each database call made on behalf of a request, so a hung database
+// fails requests instead of tying up their goroutines indefinitely. Zero leaves database
+// calls bounded only by the request's own context.
+var QueryTimeout time.Duration
+
+// withQueryTimeout derives the context for one database call from ctx, applying QueryTimeout.
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, QueryTimeout)
+}
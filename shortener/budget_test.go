@@ -0,0 +1,51 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckBudget(t *testing.T) {
+	tight, cancel := context.WithTimeout(context.Background(), MinQueryBudget/2)
+	defer cancel()
+	roomy, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		ok   bool
+	}{
+		{"no deadline", context.Background(), true},
+		{"plenty left", roomy, true},
+		{"less than MinQueryBudget left", tight, false},
+		{"cancelled", done, false},
+	}
+	for _, tt := range tests {
+		err := checkBudget(tt.ctx)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: checkBudget = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrBudgetExceeded) {
+			t.Errorf("%s: checkBudget = %v, want ErrBudgetExceeded", tt.name, err)
+		}
+	}
+}
+
+func TestRedirectSkipsDatabaseWithoutBudget(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Save(context.Background(), "abcdefg", "https://example.com/", StatusActive, LinkOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), MinQueryBudget/2)
+	defer cancel()
+
+	if _, err := HandleRedirectRequest(ctx, repo, "abcdefg"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("HandleRedirectRequest = %v, want ErrBudgetExceeded", err)
+	}
+	if clicks, _ := repo.ClickCount("abcdefg"); clicks != 1 {
+		t.Errorf("click count = %d, want 1 (not counted)", clicks)
+	}
+}
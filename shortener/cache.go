@@ -0,0 +1,25 @@
+package shortener
+
+import "context"
+
+// Cache stores short key to long URL mappings in front of the database for redirects.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached long URL for shortKey, with ok false on a cache miss.
+	Get(ctx context.Context, shortKey string) (longURL string, ok bool, err error)
+	// Set caches longURL for shortKey.
+	Set(ctx context.Context, shortKey, longURL string) error
+	// Delete removes any cached entry for shortKey.
+	Delete(ctx context.Context, shortKey string) error
+}
+
+// RedirectCache is consulted by HandleRedirectRequest before the database. It is nil
+// (disabled) unless configured at startup.
+//
+// Cache errors never fail a redirect; the database remains the source of truth.
+//
+// A cache hit only saves the lookup: without Clicks, the click is still counted with a
+// synchronous UPDATE. With Clicks as well, hot links skip the database entirely and
+// click_count is eventually consistent, lagging by up to one flush interval (or losing the
+// buffered counts if the process dies without a clean shutdown).
+var RedirectCache Cache
@@ -0,0 +1,168 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// flushTimeout bounds each batched write of buffered clicks.
+const flushTimeout = 10 * time.Second
+
+// ClickBuffer takes click counting off the redirect path. Redirects hand clicks to a
+// buffered channel, and a background goroutine aggregates them per key and writes them to
+// the database in one batched statement per flush interval, or sooner once enough clicks
+// have built up.
+//
+// Counts are eventually consistent: click_count (and the daily rollups) lag real traffic
+// by up to one flush interval, and clicks still buffered when the process dies without a
+// clean Stop are lost.
+type ClickBuffer struct {
+	db          *sql.DB
+	interval    time.Duration
+	flushEvents int
+
+	clicks  chan string
+	pending map[string]int64
+	// pendingEvents counts clicks received since the last flush attempt
+	pendingEvents int
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// Clicks is the buffer used by HandleRedirectRequest. It is nil unless configured at
+// startup, in which case clicks are counted synchronously in the redirect query.
+var Clicks *ClickBuffer
+
+// NewClickBuffer returns a buffer holding up to size unflushed clicks in its channel and
+// flushing aggregated counts every interval, or as soon as flushEvents clicks are pending
+// (0 to flush on the interval only). Call Start to begin flushing.
+func NewClickBuffer(db *sql.DB, size int, interval time.Duration, flushEvents int) *ClickBuffer {
+	return &ClickBuffer{
+		db:          db,
+		interval:    interval,
+		flushEvents: flushEvents,
+		clicks:      make(chan string, size),
+		pending:     make(map[string]int64),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start runs the background flusher.
+func (b *ClickBuffer) Start() {
+	go b.run()
+}
+
+// Stop flushes every buffered click and stops the flusher. It returns once the final flush
+// is done or ctx expires, whichever comes first. Clicks recorded after Stop are dropped.
+func (b *ClickBuffer) Stop(ctx context.Context) error {
+	close(b.stop)
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Record queues a click for shortKey without blocking. It returns false if the buffer is
+// full, in which case the caller should count the click itself.
+func (b *ClickBuffer) Record(shortKey string) bool {
+	select {
+	case b.clicks <- shortKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *ClickBuffer) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case shortKey := <-b.clicks:
+			b.pending[shortKey]++
+			b.pendingEvents++
+			if b.flushEvents > 0 && b.pendingEvents >= b.flushEvents {
+				b.flush()
+			}
+		case <-ticker.C:
+			b.flush()
+		case <-b.stop:
+			// Drain whatever is still queued so shutdown doesn't lose clicks
+			for {
+				select {
+				case shortKey := <-b.clicks:
+					b.pending[shortKey]++
+				default:
+					b.flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the aggregated counts in a single statement. On failure the counts are kept
+// and retried on the next flush.
+func (b *ClickBuffer) flush() {
+	if len(b.pending) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(b.pending))
+	counts := make([]int64, 0, len(b.pending))
+	for shortKey, count := range b.pending {
+		keys = append(keys, shortKey)
+		counts = append(counts, count)
+	}
+
+	// Counted from the last attempt, so a failing database is retried every flushEvents
+	// clicks rather than on every click
+	b.pendingEvents = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := writeClickCounts(ctx, b.db, keys, counts); err != nil {
+		slog.Error("failed to flush buffered click counts, will retry", "keys", len(keys), "error", err)
+		return
+	}
+	clear(b.pending)
+}
+
+// writeClickCounts adds counts[i] clicks to keys[i] for every i, in one transaction.
+func writeClickCounts(ctx context.Context, db *sql.DB, keys []string, counts []int64) error {
+	return WithTx(ctx, db, func(tx *sql.Tx) error {
+		query := `
+            UPDATE urls
+            SET click_count = urls.click_count + batch.clicks
+            FROM (SELECT unnest($1::text[]) AS short_key, unnest($2::bigint[]) AS clicks) AS batch
+            WHERE urls.short_key = batch.short_key
+        `
+		if _, err := tx.ExecContext(ctx, query, pq.Array(keys), pq.Array(counts)); err != nil {
+			return err
+		}
+
+		// Buffered clicks are attributed to the day they are flushed
+		if ClickTracking == ClickTrackingDaily {
+			query := `
+                INSERT INTO url_clicks_daily (short_key, day, clicks)
+                SELECT unnest($1::text[]), (now() AT TIME ZONE 'UTC')::date, unnest($2::bigint[])
+                ON CONFLICT (short_key, day) DO UPDATE SET clicks = url_clicks_daily.clicks + EXCLUDED.clicks
+            `
+			if _, err := tx.ExecContext(ctx, query, pq.Array(keys), pq.Array(counts)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
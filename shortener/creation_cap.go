@@ -0,0 +1,96 @@
+package shortener
+
+import (
+	"sync"
+	"time"
+)
+
+// CreationLimit is a global safety valve on how many links may be created per UTC day and
+// per UTC month. Counters live in memory, so each server instance enforces its own limits
+// and the counts start over when the process restarts.
+//
+// A nil *CreationLimit places no limit on link creation.
+type CreationLimit struct {
+	dailyCap   int
+	monthlyCap int
+
+	mu         sync.Mutex
+	day        time.Time
+	month      time.Time
+	dayCount   int
+	monthCount int
+
+	// now is the clock, replaceable so period boundaries can be simulated
+	now func() time.Time
+}
+
+// CreationCap is the limit checked by HandleShortURLRequest before a new link is inserted.
+// It is nil (unlimited) unless configured at startup.
+var CreationCap *CreationLimit
+
+// NewCreationLimit returns a limit allowing dailyCap links per day and monthlyCap links per
+// month. A cap of zero or less disables that period's limit.
+func NewCreationLimit(dailyCap, monthlyCap int) *CreationLimit {
+	return &CreationLimit{
+		dailyCap:   dailyCap,
+		monthlyCap: monthlyCap,
+		now:        time.Now,
+	}
+}
+
+// Reserve claims one link creation against the caps, returning ErrCapacityReached if either
+// cap has been hit for the current period. Counters reset when a new day or month begins.
+func (c *CreationLimit) Reserve() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover()
+	if c.dailyCap > 0 && c.dayCount >= c.dailyCap {
+		return ErrCapacityReached
+	}
+	if c.monthlyCap > 0 && c.monthCount >= c.monthlyCap {
+		return ErrCapacityReached
+	}
+
+	c.dayCount++
+	c.monthCount++
+	return nil
+}
+
+// Release gives back a reservation whose link ended up not being created.
+func (c *CreationLimit) Release() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover()
+	if c.dayCount > 0 {
+		c.dayCount--
+	}
+	if c.monthCount > 0 {
+		c.monthCount--
+	}
+}
+
+// rollover resets the counters whose period has ended. The caller must hold c.mu.
+func (c *CreationLimit) rollover() {
+	now := c.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if !day.Equal(c.day) {
+		c.day = day
+		c.dayCount = 0
+	}
+	if !month.Equal(c.month) {
+		c.month = month
+		c.monthCount = 0
+	}
+}
@@ -0,0 +1,94 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestCreationLimit returns a limit whose clock reads *now.
+func newTestCreationLimit(dailyCap, monthlyCap int, now *time.Time) *CreationLimit {
+	limit := NewCreationLimit(dailyCap, monthlyCap)
+	limit.now = func() time.Time { return *now }
+	return limit
+}
+
+// reserveN reserves n creations and fails the test on the first error.
+func reserveN(t *testing.T, limit *CreationLimit, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := limit.Reserve(); err != nil {
+			t.Fatalf("reservation %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestCreationLimitDailyRollover(t *testing.T) {
+	now := time.Date(2026, 3, 14, 23, 59, 0, 0, time.UTC)
+	limit := newTestCreationLimit(2, 0, &now)
+
+	reserveN(t, limit, 2)
+	if err := limit.Reserve(); !errors.Is(err, ErrCapacityReached) {
+		t.Fatalf("third reservation = %v, want ErrCapacityReached", err)
+	}
+
+	// Days are UTC days, whatever the server's zone
+	now = time.Date(2026, 3, 15, 0, 59, 0, 0, time.FixedZone("CET", 3600))
+	if err := limit.Reserve(); !errors.Is(err, ErrCapacityReached) {
+		t.Fatalf("reservation at 23:59 UTC = %v, want ErrCapacityReached", err)
+	}
+	now = time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	reserveN(t, limit, 2)
+	if err := limit.Reserve(); !errors.Is(err, ErrCapacityReached) {
+		t.Errorf("reservation after the new day's cap = %v, want ErrCapacityReached", err)
+	}
+}
+
+func TestCreationLimitMonthlyRollover(t *testing.T) {
+	now := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	limit := newTestCreationLimit(2, 3, &now)
+
+	reserveN(t, limit, 2)
+	now = now.Add(24 * time.Hour)
+	reserveN(t, limit, 1)
+	if err := limit.Reserve(); !errors.Is(err, ErrCapacityReached) {
+		t.Fatalf("reservation past the monthly cap = %v, want ErrCapacityReached", err)
+	}
+
+	now = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	reserveN(t, limit, 2)
+}
+
+func TestCreationLimitRelease(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	limit := newTestCreationLimit(1, 0, &now)
+
+	reserveN(t, limit, 1)
+	limit.Release()
+	reserveN(t, limit, 1)
+
+	var unlimited *CreationLimit
+	reserveN(t, unlimited, 10)
+	unlimited.Release()
+}
+
+func TestShortenRespectsCreationCap(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	old := CreationCap
+	CreationCap = newTestCreationLimit(1, 0, &now)
+	t.Cleanup(func() { CreationCap = old })
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	if _, err := HandleShortURLRequest(ctx, "https://example.com/a", repo, LinkOptions{}); err != nil {
+		t.Fatalf("first link: %v", err)
+	}
+	// Returning an existing link doesn't create one, so it isn't capped
+	if _, err := HandleShortURLRequest(ctx, "https://example.com/a", repo, LinkOptions{}); err != nil {
+		t.Errorf("existing link: %v", err)
+	}
+	if _, err := HandleShortURLRequest(ctx, "https://example.com/b", repo, LinkOptions{}); !errors.Is(err, ErrCapacityReached) {
+		t.Errorf("second link = %v, want ErrCapacityReached", err)
+	}
+}
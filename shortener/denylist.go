@@ -0,0 +1,61 @@
+package shortener
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// MaxDenylistPatterns bounds how many patterns every long URL is matched against
+	MaxDenylistPatterns = 500
+	// MaxDenylistPatternLength bounds the size of each compiled pattern
+	MaxDenylistPatternLength = 512
+)
+
+// urlDenylist holds the compiled patterns set by SetURLDenylist.
+var urlDenylist []*regexp.Regexp
+
+// SetURLDenylist compiles patterns and rejects any long URL matching one of them from then on.
+// It should be called once at startup, before requests are served.
+//
+// Go's regexp package guarantees matching in linear time, so patterns can't cause
+// catastrophic backtracking, but the number and size of patterns are still bounded because
+// every shortened URL is matched against all of them.
+//
+// Returns an error naming the first invalid pattern, leaving the current denylist unchanged.
+func SetURLDenylist(patterns []string) error {
+	if len(patterns) > MaxDenylistPatterns {
+		return fmt.Errorf("denylist has %d patterns, the maximum is %d", len(patterns), MaxDenylistPatterns)
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > MaxDenylistPatternLength {
+			return fmt.Errorf("denylist pattern %q exceeds %d characters", pattern, MaxDenylistPatternLength)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid denylist pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	urlDenylist = compiled
+	return nil
+}
+
+// checkDenylist rejects URLs matching an operator-configured denylist pattern.
+func checkDenylist(parsedURL *url.URL) error {
+	if len(urlDenylist) == 0 {
+		return nil
+	}
+
+	raw := parsedURL.String()
+	for _, re := range urlDenylist {
+		if re.MatchString(raw) {
+			return invalidURLf("URL matches a blocked pattern")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package shortener
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useURLDenylist sets the denylist for the rest of the test.
+func useURLDenylist(t *testing.T, patterns ...string) {
+	t.Helper()
+	old := urlDenylist
+	t.Cleanup(func() { urlDenylist = old })
+	if err := SetURLDenylist(patterns); err != nil {
+		t.Fatalf("SetURLDenylist: %v", err)
+	}
+}
+
+func TestURLDenylist(t *testing.T) {
+	useURLDenylist(t, `^https?://([^/]*\.)?evil\.example/`, `[?&]utm_campaign=spam`)
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://evil.example/login", true},
+		{"http://www.evil.example/", true},
+		{"https://example.com/?utm_campaign=spam", true},
+		{"https://example.com/page", false},
+		{"https://notevil.example/", false},
+		{"https://example.com/evil.example/", false},
+	}
+	for _, tt := range tests {
+		err := ValidateLongURL(tt.url)
+		blocked := err != nil && strings.Contains(err.Error(), "blocked pattern")
+		if blocked != tt.blocked {
+			t.Errorf("ValidateLongURL(%q) = %v, want blocked %v", tt.url, err, tt.blocked)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("ValidateLongURL(%q) = %v, want ErrInvalidURL", tt.url, err)
+		}
+	}
+}
+
+func TestSetURLDenylistRejectsBadPatterns(t *testing.T) {
+	useURLDenylist(t, `evil\.example`)
+
+	tooMany := make([]string, MaxDenylistPatterns+1)
+	for i := range tooMany {
+		tooMany[i] = "a"
+	}
+	for name, patterns := range map[string][]string{
+		"invalid":  {`ok`, `(unclosed`},
+		"too long": {strings.Repeat("a", MaxDenylistPatternLength+1)},
+		"too many": tooMany,
+	} {
+		if err := SetURLDenylist(patterns); err == nil {
+			t.Errorf("%s: SetURLDenylist succeeded, want an error", name)
+		}
+	}
+
+	// A failed update keeps the previous denylist
+	if err := ValidateLongURL("https://evil.example/"); err == nil {
+		t.Error("previous denylist was replaced by a rejected one")
+	}
+}
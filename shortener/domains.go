@@ -0,0 +1,106 @@
+package shortener
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// domainList is a set of destination domains. A plain entry such as "example.com" matches
+// the domain and all of its subdomains; a wildcard entry such as "*.corp.example" matches
+// only the subdomains.
+//
+// Entries are compared label by label against the URL's hostname rather than against its
+// registered domain (eTLD+1), since that needs the public suffix list. Listing a registered
+// domain therefore covers everything under it, as intended.
+type domainList struct {
+	domains   map[string]bool
+	wildcards map[string]bool
+}
+
+func newDomainList(entries []string) (*domainList, error) {
+	list := &domainList{domains: make(map[string]bool), wildcards: make(map[string]bool)}
+	for _, entry := range entries {
+		// Compared in Punycode form, like normalized hosts
+		entry = toASCIIHost(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), "."))
+		if entry == "" {
+			continue
+		}
+		domain, wildcard := strings.CutPrefix(entry, "*.")
+		if domain == "" || strings.ContainsAny(domain, "*/:@ ") {
+			return nil, fmt.Errorf("invalid domain %q, expected a domain such as example.com or *.example.com", entry)
+		}
+		if wildcard {
+			list.wildcards[domain] = true
+		} else {
+			list.domains[domain] = true
+		}
+	}
+	return list, nil
+}
+
+func (l *domainList) empty() bool {
+	return l == nil || len(l.domains)+len(l.wildcards) == 0
+}
+
+// matches reports whether host is covered by an entry of the list.
+func (l *domainList) matches(host string) bool {
+	if l == nil {
+		return false
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if l.domains[host] {
+		return true
+	}
+	for {
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return false
+		}
+		if l.domains[parent] || l.wildcards[parent] {
+			return true
+		}
+		host = parent
+	}
+}
+
+var (
+	// domainAllowlist, when not empty, is the only set of domains long URLs may point at
+	domainAllowlist *domainList
+	// domainBlocklist is the set of domains long URLs may never point at
+	domainBlocklist *domainList
+)
+
+// SetDomainLists restricts the domains long URLs may point at. With a non-empty allow list,
+// only URLs on those domains pass validation; URLs on a blocked domain never pass, even if
+// they are also allowed. Entries are domains like "example.com" (the domain and its
+// subdomains) or wildcards like "*.corp.example" (subdomains only). It should be called
+// once at startup.
+//
+// Returns an error naming the first invalid entry, leaving the current lists unchanged.
+func SetDomainLists(allow, block []string) error {
+	allowed, err := newDomainList(allow)
+	if err != nil {
+		return fmt.Errorf("allowlist: %w", err)
+	}
+	blocked, err := newDomainList(block)
+	if err != nil {
+		return fmt.Errorf("blocklist: %w", err)
+	}
+
+	domainAllowlist, domainBlocklist = allowed, blocked
+	return nil
+}
+
+// checkDomainLists rejects URLs on a blocked domain, or outside the allowed domains when an
+// allowlist is configured. Failures wrap ErrDomainBlocked as well as ErrInvalidURL.
+func checkDomainLists(parsedURL *url.URL) error {
+	host := parsedURL.Hostname()
+	if domainBlocklist.matches(host) {
+		return invalidURLf("%w: %s is blocked", ErrDomainBlocked, host)
+	}
+	if !domainAllowlist.empty() && !domainAllowlist.matches(host) {
+		return invalidURLf("%w: %s is not on the allowlist", ErrDomainBlocked, host)
+	}
+	return nil
+}
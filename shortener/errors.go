@@ -0,0 +1,64 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned (possibly wrapped) by the shortener functions.
+// Callers should compare against them with errors.Is rather than matching on error text.
+var (
+	// ErrInvalidKeyLength is returned when a short key does not have the expected length.
+	ErrInvalidKeyLength = errors.New("invalid short key length")
+	// ErrNotFound is returned when no servable link exists for a short key.
+	ErrNotFound = errors.New("short URL not found")
+	// ErrExpired is returned when a link exists for a short key but its expiry has passed.
+	ErrExpired = errors.New("short URL has expired")
+	// ErrDisabled is returned when a link exists for a short key but an admin has disabled it.
+	ErrDisabled = errors.New("short URL has been disabled")
+	// ErrInvalidURL is returned when a long URL fails validation.
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrCapacityReached is returned when the global link creation cap has been hit.
+	ErrCapacityReached = errors.New("link creation capacity reached, try again later")
+	// ErrBudgetExceeded is returned when a request has too little time left to start a database call.
+	ErrBudgetExceeded = errors.New("request time budget exceeded, try again later")
+	// ErrDomainBlocked is returned, alongside ErrInvalidURL, when a long URL's domain is
+	// blocked or missing from the configured allowlist.
+	ErrDomainBlocked = errors.New("destination domain not allowed")
+	// ErrDuplicateKey is returned by Repository.Save when the short key is already taken.
+	ErrDuplicateKey = errors.New("short key already exists")
+)
+
+// invalidURLError is a validation failure. It keeps the specific message for clients while
+// still matching ErrInvalidURL with errors.Is.
+type invalidURLError struct {
+	err error
+}
+
+func (e *invalidURLError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidURLError) Unwrap() []error {
+	return []error{ErrInvalidURL, e.err}
+}
+
+// invalidURLf formats a validation failure that wraps ErrInvalidURL.
+func invalidURLf(format string, args ...any) error {
+	return &invalidURLError{err: fmt.Errorf(format, args...)}
+}
+
+// ExpiredError is returned when a link exists but has expired. It matches ErrExpired with
+// errors.Is, and carries the link's replacement destination if one was set.
+type ExpiredError struct {
+	// ExpiredRedirect is where the link sends visitors after expiry, "" for none
+	ExpiredRedirect string
+}
+
+func (e *ExpiredError) Error() string {
+	return ErrExpired.Error()
+}
+
+func (e *ExpiredError) Is(target error) bool {
+	return target == ErrExpired
+}
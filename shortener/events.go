@@ -0,0 +1,172 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxEventBatch is the most click events written in one statement
+	maxEventBatch = 500
+	// maxReferrerLength and maxUserAgentLength truncate the recorded headers, which clients
+	// control, so a single visit can't write an arbitrarily large row
+	maxReferrerLength  = 2048
+	maxUserAgentLength = 512
+)
+
+// ClickEvent is one counted redirect and where it came from.
+type ClickEvent struct {
+	ShortKey  string
+	ClickedAt time.Time
+	// Referrer is the Referer header, empty for direct visits
+	Referrer  string
+	UserAgent string
+}
+
+// ClickEventRecorder writes click events to the click_events table off the redirect path.
+// Redirects hand events to a buffered channel, and a background goroutine inserts them in
+// batches every flush interval, or sooner once a batch is full.
+//
+// Events are best effort: they are dropped when the buffer is full or a write fails, so a
+// failing database never slows down or fails redirects. Events still buffered when the
+// process dies without a clean Stop are lost.
+type ClickEventRecorder struct {
+	db       *sql.DB
+	interval time.Duration
+
+	events  chan ClickEvent
+	pending []ClickEvent
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// ClickEvents records the referrer and user agent of counted redirects. It is nil unless
+// configured at startup, in which case no events are recorded.
+var ClickEvents *ClickEventRecorder
+
+// NewClickEventRecorder returns a recorder holding up to size unwritten events in its
+// channel and writing them every interval. Call Start to begin writing.
+func NewClickEventRecorder(db *sql.DB, size int, interval time.Duration) *ClickEventRecorder {
+	return &ClickEventRecorder{
+		db:       db,
+		interval: interval,
+		events:   make(chan ClickEvent, size),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the background writer.
+func (r *ClickEventRecorder) Start() {
+	go r.run()
+}
+
+// Stop writes every buffered event and stops the writer. It returns once the final write is
+// done or ctx expires, whichever comes first. Events recorded after Stop are dropped.
+func (r *ClickEventRecorder) Stop(ctx context.Context) error {
+	close(r.stop)
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Record queues a click event for shortKey without blocking. It returns false if the buffer
+// is full and the event was dropped.
+func (r *ClickEventRecorder) Record(shortKey, referrer, userAgent string) bool {
+	event := ClickEvent{
+		ShortKey:  shortKey,
+		ClickedAt: time.Now(),
+		Referrer:  truncate(referrer, maxReferrerLength),
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+	}
+	select {
+	case r.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ClickEventRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-r.events:
+			r.pending = append(r.pending, event)
+			if len(r.pending) >= maxEventBatch {
+				r.flush()
+			}
+		case <-ticker.C:
+			r.flush()
+		case <-r.stop:
+			// Drain whatever is still queued so shutdown doesn't lose events
+			for {
+				select {
+				case event := <-r.events:
+					r.pending = append(r.pending, event)
+					if len(r.pending) >= maxEventBatch {
+						r.flush()
+					}
+				default:
+					r.flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the pending events in a single statement. Failed batches are logged and
+// dropped rather than retried, so a database outage can't grow memory without bound.
+func (r *ClickEventRecorder) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	defer func() { r.pending = r.pending[:0] }()
+
+	keys := make([]string, len(r.pending))
+	times := make([]time.Time, len(r.pending))
+	referrers := make([]sql.NullString, len(r.pending))
+	userAgents := make([]string, len(r.pending))
+	for i, event := range r.pending {
+		keys[i] = event.ShortKey
+		times[i] = event.ClickedAt
+		referrers[i] = sql.NullString{String: event.Referrer, Valid: event.Referrer != ""}
+		userAgents[i] = event.UserAgent
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO click_events (short_key, clicked_at, referrer, user_agent)
+        SELECT unnest($1::text[]), unnest($2::timestamptz[]), unnest($3::text[]), unnest($4::text[])
+    `
+	_, err := r.db.ExecContext(ctx, query, pq.Array(keys), pq.Array(times), pq.Array(referrers), pq.Array(userAgents))
+	if err != nil {
+		slog.Error("failed to write click events, dropping them", "events", len(r.pending), "error", err)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,127 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeResult is what a fakeDB handler answers a statement with: rows for queries, a row
+// count for everything else.
+type fakeResult struct {
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+}
+
+// fakeHandler answers one statement sent to a fakeDB.
+type fakeHandler func(query string, args []driver.NamedValue) (fakeResult, error)
+
+var (
+	fakeDriverOnce sync.Once
+	fakeHandlers   sync.Map
+	fakeDBCount    atomic.Int64
+)
+
+// newFakeDB returns a *sql.DB whose statements are all answered by handler, so code written
+// against Querier can be tested without Postgres.
+func newFakeDB(t *testing.T, handler fakeHandler) *sql.DB {
+	t.Helper()
+	fakeDriverOnce.Do(func() { sql.Register("fakedb", fakeDriver{}) })
+
+	name := fmt.Sprintf("fakedb-%d", fakeDBCount.Add(1))
+	fakeHandlers.Store(name, handler)
+	db, err := sql.Open("fakedb", name)
+	if err != nil {
+		t.Fatalf("opening fake database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeHandlers.Delete(name)
+	})
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	handler, ok := fakeHandlers.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake database " + name)
+	}
+	return &fakeConn{handler: handler.(fakeHandler)}, nil
+}
+
+type fakeConn struct {
+	handler fakeHandler
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{handler: c.handler}, nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{handler: c.handler}, nil
+}
+
+// CheckNamedValue passes every argument to the handler as given.
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.handler(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.handler(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+// fakeTx sends COMMIT and ROLLBACK to the handler as statements of their own.
+type fakeTx struct {
+	handler fakeHandler
+}
+
+func (tx fakeTx) Commit() error {
+	_, err := tx.handler("COMMIT", nil)
+	return err
+}
+
+func (tx fakeTx) Rollback() error {
+	_, err := tx.handler("ROLLBACK", nil)
+	return err
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,29 @@
+package shortener
+
+import (
+	"net/url"
+	"strings"
+)
+
+// FaviconHostPlaceholder is replaced with the destination host in FaviconService.
+const FaviconHostPlaceholder = "{host}"
+
+// FaviconService is a URL template for favicon lookups, such as
+// "https://www.google.com/s2/favicons?domain={host}". When empty, favicon URLs point at
+// /favicon.ico on the destination itself.
+var FaviconService = ""
+
+// FaviconURL returns the favicon URL for a link's destination, or "" if the destination has
+// no host. The URL is only constructed; nothing is fetched.
+func FaviconURL(longURL string) string {
+	parsed, err := url.Parse(longURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	if FaviconService != "" {
+		return strings.ReplaceAll(FaviconService, FaviconHostPlaceholder, url.QueryEscape(parsed.Hostname()))
+	}
+	favicon := url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/favicon.ico"}
+	return favicon.String()
+}
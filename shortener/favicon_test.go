@@ -0,0 +1,25 @@
+package shortener
+
+import "testing"
+
+func TestFaviconURL(t *testing.T) {
+	tests := []struct {
+		service, longURL, want string
+	}{
+		{"", "https://example.com/a/b?c=d", "https://example.com/favicon.ico"},
+		{"", "http://example.com:8080/page", "http://example.com:8080/favicon.ico"},
+		{"", "https://user@example.com/", "https://example.com/favicon.ico"},
+		{"https://icons.example/s2/favicons?domain={host}", "https://docs.example.com:8443/x", "https://icons.example/s2/favicons?domain=docs.example.com"},
+		{"https://icons.example/{host}.ico", "https://[2001:db8::1]/", "https://icons.example/2001%3Adb8%3A%3A1.ico"},
+		{"", "not a url", ""},
+		{"", "/relative/path", ""},
+	}
+	old := FaviconService
+	t.Cleanup(func() { FaviconService = old })
+	for _, tt := range tests {
+		FaviconService = tt.service
+		if got := FaviconURL(tt.longURL); got != tt.want {
+			t.Errorf("FaviconURL(%q) with service %q = %q, want %q", tt.longURL, tt.service, got, tt.want)
+		}
+	}
+}
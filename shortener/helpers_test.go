@@ -0,0 +1,35 @@
+package shortener
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+// mustParseURL parses rawURL or fails the test.
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", rawURL, err)
+	}
+	return parsed
+}
+
+// useStrategy switches the key strategy for the rest of the test.
+func useStrategy(t *testing.T, strategy KeyStrategy) {
+	t.Helper()
+	old := Strategy
+	Strategy = strategy
+	t.Cleanup(func() { Strategy = old })
+}
+
+// createLink shortens longURL in repo and returns its key.
+func createLink(t *testing.T, repo Repository, longURL string, opts LinkOptions) string {
+	t.Helper()
+	result, err := HandleShortURLRequest(context.Background(), longURL, repo, opts)
+	if err != nil {
+		t.Fatalf("shortening %q: %v", longURL, err)
+	}
+	return result.ShortKey
+}
@@ -0,0 +1,120 @@
+package shortener
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// RejectMixedScriptHosts rejects hostnames with a label mixing Latin, Cyrillic, and Greek
+// letters, such as "аpple.com" with a Cyrillic "а". Those scripts share many lookalike
+// letters, and legitimate names almost never mix them within one label, so it is on by default.
+var RejectMixedScriptHosts = true
+
+// acePrefix marks a label encoded with Punycode.
+const acePrefix = "xn--"
+
+// toASCIIHost converts an internationalized hostname to its ASCII form with the IDNA lookup
+// profile (UTS #46), mapping and normalizing it and encoding every non-ASCII label with
+// Punycode ("Bücher.example" becomes "xn--bcher-kva.example"), so each domain has one
+// canonical spelling. ASCII hosts, including IP literals, are returned unchanged.
+//
+// Hosts IDNA rejects are returned unchanged for validation to reject, as are hosts with
+// control or formatting characters: IDNA would silently drop some of those, such as the
+// zero width space, turning a spoofed name into the real one.
+func toASCIIHost(host string) string {
+	if isASCII(host) || hasInvisibleChars(host) {
+		return host
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return host
+	}
+	return ascii
+}
+
+// hostLabels returns the labels of host in Unicode form. Non-ASCII and Punycode labels are
+// decoded and validated with the IDNA lookup profile; other ASCII labels are returned as is,
+// so hostnames IDNA is stricter about, like ones containing "_", still pass.
+func hostLabels(host string) ([]string, error) {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) && !strings.HasPrefix(strings.ToLower(label), acePrefix) {
+			continue
+		}
+		decoded, err := idna.Lookup.ToUnicode(label)
+		if err != nil {
+			return nil, err
+		}
+		labels[i] = decoded
+	}
+	return labels, nil
+}
+
+// checkIDNHost rejects hostnames with labels IDNA considers invalid and, with
+// RejectMixedScriptHosts, labels mixing lookalike scripts. Punycode labels are decoded
+// first, so the checks can't be bypassed by submitting the ASCII form; decoded labels are
+// also held to checkHostCharacters' rule against control and formatting characters.
+func checkIDNHost(parsedURL *url.URL) error {
+	labels, err := hostLabels(parsedURL.Hostname())
+	if err != nil {
+		return invalidURLf("URL host contains an invalid internationalized label")
+	}
+	for _, label := range labels {
+		if hasInvisibleChars(label) {
+			return invalidURLf("URL host contains invalid characters")
+		}
+	}
+	if !RejectMixedScriptHosts {
+		return nil
+	}
+	for _, label := range labels {
+		if mixesConfusableScripts(label) {
+			return invalidURLf("URL host mixes lookalike scripts in %q", label)
+		}
+	}
+	return nil
+}
+
+// hasInvisibleChars reports whether s contains control or formatting characters, such as
+// the zero width space or the right-to-left override.
+func hasInvisibleChars(s string) bool {
+	for _, char := range s {
+		if unicode.IsControl(char) || unicode.Is(unicode.Cf, char) {
+			return true
+		}
+	}
+	return false
+}
+
+// confusableScripts are the scripts whose letters are easily mistaken for one another.
+var confusableScripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek}
+
+// mixesConfusableScripts reports whether label has letters from more than one of the
+// confusableScripts. Digits, hyphens, and letters of other scripts don't count.
+func mixesConfusableScripts(label string) bool {
+	seen := -1
+	for _, char := range label {
+		for i, script := range confusableScripts {
+			if unicode.Is(script, char) {
+				if seen >= 0 && seen != i {
+					return true
+				}
+				seen = i
+			}
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
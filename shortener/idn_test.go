@@ -0,0 +1,99 @@
+package shortener
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToASCIIHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"127.0.0.1", "127.0.0.1"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example", "xn--bcher-kva.example"},
+		{"ｅｘａｍｐｌｅ.com", "example.com"},
+		{"münchen.bücher.example", "xn--mnchen-3ya.xn--bcher-kva.example"},
+		// Left alone so validation can reject them rather than IDNA silently dropping the
+		// invisible character
+		{"exa\u200bmple.com", "exa\u200bmple.com"},
+		{"goo\u202egle.com", "goo\u202egle.com"},
+	}
+	for _, tt := range tests {
+		if got := toASCIIHost(tt.host); got != tt.want {
+			t.Errorf("toASCIIHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostLabelsRoundTrip(t *testing.T) {
+	for _, host := range []string{"bücher.example", "пример.испытание", "例え.テスト", "my_host.example.com"} {
+		labels, err := hostLabels(toASCIIHost(host))
+		if err != nil {
+			t.Errorf("hostLabels(toASCIIHost(%q)) failed: %v", host, err)
+			continue
+		}
+		if got := strings.Join(labels, "."); got != host {
+			t.Errorf("hostLabels(toASCIIHost(%q)) = %q, want it back unchanged", host, got)
+		}
+	}
+}
+
+func TestValidateLongURLInvisibleHostCharacters(t *testing.T) {
+	urls := []string{
+		"https://exa\u200bmple.com/", // zero width space
+		"https://goo\u202egle.com/",  // right-to-left override
+		"https://exam\u00adple.com/", // soft hyphen
+		"https://xn--example-2z6c.com/",
+	}
+	for _, longURL := range urls {
+		// Links are normalized before they are validated, so both orders must reject
+		if err := ValidateLongURL(longURL); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("ValidateLongURL(%q) = %v, want ErrInvalidURL", longURL, err)
+		}
+		if err := ValidateLongURL(NormalizeURL(longURL)); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("ValidateLongURL(NormalizeURL(%q)) = %v, want ErrInvalidURL", longURL, err)
+		}
+	}
+}
+
+func TestCheckIDNHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"my_host.example.com", false},
+		{"xn--bcher-kva.example", false},
+		{"bücher.example", false},
+		{"xn--zz.example", true},          // not valid Punycode for a label
+		{"xn--pple-43d.com", true},        // "аpple" with a Cyrillic "а"
+		{"аpple.com", true},               // the same, unencoded
+		{"xn--80ak6aa92e.com", false},     // all Cyrillic, so not mixed
+		{"xn--example-2z6c.com", true},    // zero width space
+		{"xn--google-dm0c.com", true},     // right-to-left override
+		{"ελληνικά.example", false},       // all Greek
+		{"xn--hxargifdar.example", false}, // the same, encoded
+	}
+	for _, tt := range tests {
+		err := checkIDNHost(mustParseURL(t, "https://"+tt.host+"/"))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkIDNHost(%q) = %v, want error %v", tt.host, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckIDNHostMixedScriptsAllowed(t *testing.T) {
+	defer func(old bool) { RejectMixedScriptHosts = old }(RejectMixedScriptHosts)
+	RejectMixedScriptHosts = false
+
+	if err := checkIDNHost(mustParseURL(t, "https://xn--pple-43d.com/")); err != nil {
+		t.Errorf("checkIDNHost with RejectMixedScriptHosts off = %v, want nil", err)
+	}
+	if err := checkIDNHost(mustParseURL(t, "https://xn--example-2z6c.com/")); err == nil {
+		t.Error("checkIDNHost accepted a zero width space with RejectMixedScriptHosts off")
+	}
+}
@@ -0,0 +1,134 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyStrategy decides how short keys are produced for new links.
+type KeyStrategy interface {
+	// Generate returns the key to try for longURL on the given attempt. Attempts start at 0
+	// and only increase when the previous key collided.
+	Generate(ctx context.Context, repo Repository, longURL string, attempt int) (string, error)
+	// Unique reports whether generated keys can never collide. Strategies with unique keys
+	// skip the dedup lookup and collision retries, since every call yields a fresh key.
+	Unique() bool
+	// Name is the strategy's KEY_STRATEGY value.
+	Name() string
+}
+
+// HashStrategy derives keys from a salted SHA256 hash of the long URL, keyed with KeySecret
+// when one is configured. Keys are deterministic, which lets the same URL be deduplicated to
+// the same key, but collisions have to be retried with a new salt.
+type HashStrategy struct{}
+
+func (HashStrategy) Generate(ctx context.Context, repo Repository, longURL string, attempt int) (string, error) {
+	return generateShortURLKey(longURL, attempt), nil
+}
+
+func (HashStrategy) Unique() bool { return false }
+
+func (HashStrategy) Name() string { return "hash" }
+
+// SequentialStrategy base62-encodes the next ID from the repository (the short_key_seq
+// sequence in Postgres). Keys are KeyLength characters until the sequence outgrows that
+// width, and then grow by a character at a time.
+// Keys never collide, so no retries or dedup lookups are needed, but consecutive keys are
+// guessable and every request for the same long URL creates a new link.
+type SequentialStrategy struct{}
+
+func (SequentialStrategy) Generate(ctx context.Context, repo Repository, longURL string, attempt int) (string, error) {
+	id, err := repo.NextID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeBase62(id, KeyLength)
+}
+
+func (SequentialStrategy) Unique() bool { return true }
+
+func (SequentialStrategy) Name() string { return "sequential" }
+
+// Strategy is the key generation strategy used by HandleShortURLRequest.
+var Strategy KeyStrategy = HashStrategy{}
+
+// NewKeyStrategy returns the strategy with the given name, "hash" or "sequential".
+func NewKeyStrategy(name string) (KeyStrategy, error) {
+	switch name {
+	case "hash":
+		return HashStrategy{}, nil
+	case "sequential":
+		return SequentialStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown key strategy %q, expected \"hash\" or \"sequential\"", name)
+	}
+}
+
+// base64URLAlphabet is the alphabet of keys produced by HashStrategy.
+const base64URLAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// KeygenConfig describes the active key generation settings, for operators verifying a deployment.
+type KeygenConfig struct {
+	Strategy      string `json:"strategy"`
+	KeyLength     int    `json:"key_length"`
+	Alphabet      string `json:"alphabet"`
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+	// PepperConfigured reports whether KEY_SECRET is mixed into hashed keys; the secret
+	// itself is never reported
+	PepperConfigured bool `json:"pepper_configured"`
+	MaxRetries       int  `json:"max_retries"`
+}
+
+// CurrentKeygenConfig reports the key generation settings currently in effect.
+func CurrentKeygenConfig() KeygenConfig {
+	config := KeygenConfig{
+		Strategy:   Strategy.Name(),
+		KeyLength:  KeyLength,
+		MaxRetries: MaxRetries,
+	}
+
+	switch Strategy.(type) {
+	case HashStrategy:
+		config.Alphabet = base64URLAlphabet
+		config.HashAlgorithm = "sha256"
+		if len(KeySecret) > 0 {
+			config.HashAlgorithm = "hmac-sha256"
+			config.PepperConfigured = true
+		}
+	case SequentialStrategy:
+		config.Alphabet = base62Alphabet
+		// Sequential keys never collide, so they are never retried
+		config.MaxRetries = 0
+	}
+
+	return config
+}
+
+// base62Alphabet only contains characters that are valid in short keys.
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encodeBase62 encodes a non-negative id in base62, left-padded with zeros to at least
+// length. Ids too large for length digits produce longer keys rather than failing, so the
+// sequence never runs out of keys; validateShortKeyLength accepts them for this strategy.
+func encodeBase62(id int64, length int) (string, error) {
+	if id < 0 {
+		return "", errors.New("cannot encode negative id")
+	}
+
+	var digits []byte
+	for id > 0 {
+		digits = append(digits, base62Alphabet[id%62])
+		id /= 62
+	}
+	var b strings.Builder
+	b.Grow(length)
+	for i := len(digits); i < length; i++ {
+		b.WriteByte(base62Alphabet[0])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		b.WriteByte(digits[i])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,104 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBase62(t *testing.T) {
+	tests := []struct {
+		id     int64
+		length int
+		want   string
+	}{
+		{0, 7, "0000000"},
+		{1, 7, "0000001"},
+		{61, 7, "000000z"},
+		{62, 7, "0000010"},
+		{62*62*62 - 1, 3, "zzz"},
+		// Ids past the width grow the key instead of failing
+		{62 * 62 * 62, 3, "1000"},
+		{9223372036854775807, 7, "AzL8n0Y58m7"},
+	}
+	for _, tt := range tests {
+		got, err := encodeBase62(tt.id, tt.length)
+		if err != nil || got != tt.want {
+			t.Errorf("encodeBase62(%d, %d) = %q, %v, want %q", tt.id, tt.length, got, err, tt.want)
+		}
+	}
+	if _, err := encodeBase62(-1, 7); err == nil {
+		t.Error("encodeBase62(-1) succeeded, want an error")
+	}
+}
+
+func TestSequentialStrategyCreatesDistinctKeys(t *testing.T) {
+	useStrategy(t, SequentialStrategy{})
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		// The same URL still gets a fresh link, since sequential keys skip the dedup lookup
+		result, err := HandleShortURLRequest(ctx, "https://example.com/", repo, LinkOptions{})
+		if err != nil {
+			t.Fatalf("HandleShortURLRequest: %v", err)
+		}
+		if !result.Created || len(result.ShortKey) != KeyLength || seen[result.ShortKey] {
+			t.Fatalf("shorten %d = %+v, want a new %d character key", i, result, KeyLength)
+		}
+		seen[result.ShortKey] = true
+
+		if _, err := HandleRedirectRequest(ctx, repo, result.ShortKey); err != nil {
+			t.Errorf("HandleRedirectRequest(%q): %v", result.ShortKey, err)
+		}
+	}
+}
+
+func TestValidateShortKeyLength(t *testing.T) {
+	tests := []struct {
+		strategy KeyStrategy
+		length   int
+		ok       bool
+	}{
+		{HashStrategy{}, KeyLength, true},
+		{HashStrategy{}, KeyLength - 1, false},
+		{HashStrategy{}, KeyLength + 1, false},
+		{SequentialStrategy{}, KeyLength, true},
+		{SequentialStrategy{}, KeyLength - 1, false},
+		{SequentialStrategy{}, KeyLength + 1, true},
+		{SequentialStrategy{}, MaxKeyLength, true},
+		{SequentialStrategy{}, MaxKeyLength + 1, false},
+	}
+	for _, tt := range tests {
+		useStrategy(t, tt.strategy)
+		err := validateShortKeyLength(strings.Repeat("a", tt.length))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s strategy, length %d: err = %v, want ok %v", tt.strategy.Name(), tt.length, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidKeyLength) {
+			t.Errorf("%s strategy, length %d: err = %v, want ErrInvalidKeyLength", tt.strategy.Name(), tt.length, err)
+		}
+	}
+}
+
+func TestCurrentKeygenConfig(t *testing.T) {
+	old := KeySecret
+	t.Cleanup(func() { KeySecret = old })
+
+	KeySecret = nil
+	if config := CurrentKeygenConfig(); config.HashAlgorithm != "sha256" || config.PepperConfigured {
+		t.Errorf("without a pepper: %+v, want plain sha256", config)
+	}
+	KeySecret = []byte("pepper-0123456789abcdef")
+	if config := CurrentKeygenConfig(); config.HashAlgorithm != "hmac-sha256" || !config.PepperConfigured {
+		t.Errorf("with a pepper: %+v, want hmac-sha256 with the pepper reported", config)
+	}
+
+	useStrategy(t, SequentialStrategy{})
+	want := KeygenConfig{Strategy: "sequential", KeyLength: KeyLength, Alphabet: base62Alphabet}
+	if config := CurrentKeygenConfig(); config != want {
+		t.Errorf("sequential: %+v, want %+v", config, want)
+	}
+}
@@ -0,0 +1,158 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// How long URLs on a known shortener domain are handled.
+const (
+	// KnownShortenersAllow shortens them like any other URL
+	KnownShortenersAllow = "allow"
+	// KnownShortenersReject fails validation for them
+	KnownShortenersReject = "reject"
+	// KnownShortenersResolve follows their redirects and shortens the final destination
+	KnownShortenersResolve = "resolve"
+)
+
+// MaxShortenerHops bounds how many shortener redirects are followed in resolve mode, so
+// chains (or loops) of short links can't hold a request open.
+const MaxShortenerHops = 5
+
+// DefaultKnownShorteners are the shortener domains recognized out of the box.
+var DefaultKnownShorteners = []string{
+	"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly",
+	"rb.gy", "rebrand.ly", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+}
+
+var (
+	// KnownShortenerMode is one of KnownShortenersAllow, KnownShortenersReject, or
+	// KnownShortenersResolve. Set it with SetKnownShorteners.
+	KnownShortenerMode = KnownShortenersAllow
+	// knownShorteners is the set of lowercased shortener domains
+	knownShorteners = makeDomainSet(DefaultKnownShorteners)
+	// ShortenerResolveTimeout bounds each request made to a shortener in resolve mode.
+	ShortenerResolveTimeout = 5 * time.Second
+)
+
+// shortenerClient makes the requests used to resolve short links. It never follows
+// redirects itself, so each hop can be inspected before the next one is made.
+var shortenerClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// SetKnownShorteners sets how URLs on known shortener domains are handled, and extends the
+// default domain list with extra. It should be called once at startup.
+func SetKnownShorteners(mode string, extra []string) error {
+	switch mode {
+	case KnownShortenersAllow, KnownShortenersReject, KnownShortenersResolve:
+	default:
+		return fmt.Errorf("unknown shortener mode %q, expected %q, %q, or %q",
+			mode, KnownShortenersAllow, KnownShortenersReject, KnownShortenersResolve)
+	}
+
+	KnownShortenerMode = mode
+	knownShorteners = makeDomainSet(append(append([]string{}, DefaultKnownShorteners...), extra...))
+	return nil
+}
+
+func makeDomainSet(domains []string) map[string]bool {
+	set := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			set[domain] = true
+		}
+	}
+	return set
+}
+
+// isKnownShortener reports whether host is, or is a subdomain of, a known shortener domain.
+func isKnownShortener(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for host != "" {
+		if knownShorteners[host] {
+			return true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// checkKnownShortener rejects URLs on a known shortener domain in reject mode. In resolve
+// mode they pass, since HandleShortURLRequest resolves them before validating.
+func checkKnownShortener(parsedURL *url.URL) error {
+	if KnownShortenerMode == KnownShortenersReject && isKnownShortener(parsedURL.Hostname()) {
+		return invalidURLf("URLs from other link shorteners are not allowed")
+	}
+	return nil
+}
+
+// resolveKnownShortener follows the redirects of a URL on a known shortener domain until it
+// reaches a URL elsewhere, which is returned. Other URLs, and every URL outside resolve
+// mode, are returned unchanged. Only known shortener hosts are ever contacted.
+//
+// Returns an error wrapping ErrInvalidURL if a short link can't be resolved within
+// MaxShortenerHops.
+func resolveKnownShortener(ctx context.Context, longURL string) (string, error) {
+	if KnownShortenerMode != KnownShortenersResolve {
+		return longURL, nil
+	}
+
+	current := longURL
+	for range MaxShortenerHops {
+		parsedURL, err := url.Parse(current)
+		if err != nil {
+			return "", invalidURLf("invalid url format %w", err)
+		}
+		if !isKnownShortener(parsedURL.Hostname()) {
+			return current, nil
+		}
+		if err := checkScheme(parsedURL); err != nil {
+			return "", err
+		}
+
+		location, err := fetchRedirectLocation(ctx, current)
+		if err != nil {
+			return "", invalidURLf("could not resolve shortened URL: %w", err)
+		}
+		next, err := parsedURL.Parse(location)
+		if err != nil {
+			return "", invalidURLf("shortened URL redirects to an invalid location: %w", err)
+		}
+		current = next.String()
+	}
+
+	return "", invalidURLf("shortened URL redirects more than %d times", MaxShortenerHops)
+}
+
+// fetchRedirectLocation issues a HEAD request for rawURL and returns its Location header.
+func fetchRedirectLocation(ctx context.Context, rawURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, ShortenerResolveTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := shortenerClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("no redirect (status %d)", resp.StatusCode)
+	}
+	return location, nil
+}
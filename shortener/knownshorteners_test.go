@@ -0,0 +1,93 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc lets a function stand in for the network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// useKnownShorteners sets the known shortener mode for the rest of the test. In resolve
+// mode, short links redirect according to redirects (URL to Location) instead of the network.
+func useKnownShorteners(t *testing.T, mode string, redirects map[string]string) {
+	t.Helper()
+	oldMode, oldSet, oldTransport := KnownShortenerMode, knownShorteners, shortenerClient.Transport
+	t.Cleanup(func() {
+		KnownShortenerMode, knownShorteners, shortenerClient.Transport = oldMode, oldSet, oldTransport
+	})
+	if err := SetKnownShorteners(mode, []string{"sho.rt"}); err != nil {
+		t.Fatalf("SetKnownShorteners: %v", err)
+	}
+	shortenerClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodHead {
+			t.Errorf("resolving with %s, want HEAD", r.Method)
+		}
+		resp := &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: http.NoBody, Request: r}
+		if location, ok := redirects[r.URL.String()]; ok {
+			resp.StatusCode = http.StatusMovedPermanently
+			resp.Header.Set("Location", location)
+		}
+		return resp, nil
+	})
+}
+
+func TestKnownShortenerModes(t *testing.T) {
+	redirects := map[string]string{
+		"https://bit.ly/abc":      "https://tinyurl.com/xyz",
+		"https://tinyurl.com/xyz": "https://example.com/final?src=chain",
+		"https://sho.rt/rel":      "/elsewhere",
+		"https://sho.rt/loop":     "https://sho.rt/loop",
+		"https://bit.ly/internal": "http://127.0.0.1/admin",
+	}
+	tests := []struct {
+		mode, url, want string
+	}{
+		{KnownShortenersAllow, "https://bit.ly/abc", "https://bit.ly/abc"},
+		{KnownShortenersAllow, "https://example.com/", "https://example.com/"},
+		{KnownShortenersReject, "https://bit.ly/abc", "other link shorteners"},
+		{KnownShortenersReject, "https://www.BIT.ly./abc", "other link shorteners"},
+		{KnownShortenersReject, "https://sho.rt/abc", "other link shorteners"},
+		{KnownShortenersReject, "https://notbit.ly/abc", "https://notbit.ly/abc"},
+		{KnownShortenersResolve, "https://bit.ly/abc", "https://example.com/final?src=chain"},
+		{KnownShortenersResolve, "https://example.com/", "https://example.com/"},
+		{KnownShortenersResolve, "https://sho.rt/rel", "could not resolve"},
+		{KnownShortenersResolve, "https://sho.rt/loop", "more than 5 times"},
+		{KnownShortenersResolve, "https://bit.ly/missing", "could not resolve"},
+		// The destination is validated like any other URL
+		{KnownShortenersResolve, "https://bit.ly/internal", "private"},
+	}
+	for _, tt := range tests {
+		useKnownShorteners(t, tt.mode, redirects)
+		repo := NewMemoryRepository()
+		result, err := HandleShortURLRequest(context.Background(), tt.url, repo, LinkOptions{})
+		if !strings.HasPrefix(tt.want, "https://") {
+			if !errors.Is(err, ErrInvalidURL) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s %s: %+v, %v, want an ErrInvalidURL mentioning %q", tt.mode, tt.url, result, err, tt.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.mode, tt.url, err)
+			continue
+		}
+		if longURL, _, _ := repo.Get(context.Background(), result.ShortKey); longURL != tt.want {
+			t.Errorf("%s %s: shortened %q, want %q", tt.mode, tt.url, longURL, tt.want)
+		}
+	}
+}
+
+func TestSetKnownShortenersRejectsUnknownModes(t *testing.T) {
+	useKnownShorteners(t, KnownShortenersAllow, nil)
+	if err := SetKnownShorteners("follow", nil); err == nil {
+		t.Error("SetKnownShorteners(follow) succeeded, want an error")
+	}
+	if KnownShortenerMode != KnownShortenersAllow {
+		t.Errorf("mode = %q after a rejected mode, want it unchanged", KnownShortenerMode)
+	}
+}
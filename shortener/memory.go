@@ -0,0 +1,170 @@
+package shortener
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a Repository keeping links in memory, for tests and local experiments
+// without a database. It follows the same rules as PostgresRepository for which links
+// resolve and how clicks are counted, but it is not persistent and is per process.
+type MemoryRepository struct {
+	mu    sync.Mutex
+	links map[string]*memoryLink
+	// byURL lists the keys of plain links (no per-link options) by long URL, oldest first
+	byURL  map[string][]string
+	nextID int64
+}
+
+// memoryLink is one stored link.
+type memoryLink struct {
+	longURL string
+	status  string
+	opts    LinkOptions
+	active  bool
+	clicks  int64
+}
+
+// target returns where the link redirects, like redirectColumn.
+func (l *memoryLink) target() string {
+	if l.opts.OriginalURL != "" {
+		return l.opts.OriginalURL
+	}
+	return l.longURL
+}
+
+// NewMemoryRepository returns an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		links: make(map[string]*memoryLink),
+		byURL: make(map[string][]string),
+	}
+}
+
+// FindByLongURL returns the oldest plain link for longURL that is approved and enabled, with
+// the same rules as CheckDbForLongURL.
+func (m *MemoryRepository) FindByLongURL(ctx context.Context, longURL string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, shortKey := range m.byURL[longURL] {
+		link := m.links[shortKey]
+		if link.status == StatusActive && link.active {
+			return shortKey, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *MemoryRepository) Save(ctx context.Context, shortKey, longURL, status string, opts LinkOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[shortKey]; ok {
+		return ErrDuplicateKey
+	}
+	link := &memoryLink{longURL: longURL, status: status, opts: opts, active: true, clicks: 1}
+	if opts.MaxClicks > 0 {
+		// As in Postgres, limited links start at 0 so the limit counts real visits
+		link.clicks = 0
+	}
+	m.links[shortKey] = link
+	if opts.isZero() {
+		m.byURL[longURL] = append(m.byURL[longURL], shortKey)
+	}
+	return nil
+}
+
+func (m *MemoryRepository) IncrementAndGet(ctx context.Context, shortKey string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, err := m.resolve(shortKey)
+	if err != nil {
+		return "", err
+	}
+	link.clicks++
+	return link.target(), nil
+}
+
+func (m *MemoryRepository) Increment(ctx context.Context, shortKey string) error {
+	_, err := m.IncrementAndGet(ctx, shortKey)
+	return err
+}
+
+func (m *MemoryRepository) Get(ctx context.Context, shortKey string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, err := m.resolve(shortKey)
+	if err != nil {
+		return "", false, err
+	}
+	return link.target(), link.opts.MaxClicks > 0, nil
+}
+
+func (m *MemoryRepository) NextID(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	return m.nextID, nil
+}
+
+// SetStatus changes the status of shortKey's link, as approving a pending link does. It
+// returns ErrNotFound if there is no such link.
+func (m *MemoryRepository) SetStatus(shortKey, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortKey]
+	if !ok {
+		return ErrNotFound
+	}
+	link.status = status
+	return nil
+}
+
+// SetActive disables (false) or re-enables (true) shortKey's link. It returns ErrNotFound if
+// there is no such link.
+func (m *MemoryRepository) SetActive(shortKey string, active bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortKey]
+	if !ok {
+		return ErrNotFound
+	}
+	link.active = active
+	return nil
+}
+
+// ClickCount returns the click count of shortKey's link, and false if there is no such link.
+func (m *MemoryRepository) ClickCount(shortKey string) (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortKey]
+	if !ok {
+		return 0, false
+	}
+	return link.clicks, true
+}
+
+// resolve returns shortKey's link if it currently redirects, or the error explaining why
+// not, with the same rules as resolvablePredicate and classifyMissingLink. m.mu must be held.
+func (m *MemoryRepository) resolve(shortKey string) (*memoryLink, error) {
+	link, ok := m.links[shortKey]
+	if !ok || link.status != StatusActive {
+		return nil, ErrNotFound
+	}
+	if !link.active {
+		return nil, ErrDisabled
+	}
+	expired := link.opts.ExpiresAt != nil && !link.opts.ExpiresAt.After(time.Now())
+	usedUp := link.opts.MaxClicks > 0 && link.clicks >= int64(link.opts.MaxClicks)
+	if expired || usedUp {
+		return nil, &ExpiredError{ExpiredRedirect: link.opts.ExpiredRedirect}
+	}
+	return link, nil
+}
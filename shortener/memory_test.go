@@ -0,0 +1,113 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var _ Repository = (*MemoryRepository)(nil)
+
+func TestMemoryRepositoryShortenAndRedirect(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	first, err := HandleShortURLRequest(ctx, "https://example.com/page", repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if !first.Created || len(first.ShortKey) != KeyLength {
+		t.Fatalf("first shorten = %+v, want a new %d character key", first, KeyLength)
+	}
+
+	again, err := HandleShortURLRequest(ctx, "https://example.com/page", repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest again: %v", err)
+	}
+	if again.Created || again.ShortKey != first.ShortKey {
+		t.Errorf("second shorten = %+v, want the existing key %q reused", again, first.ShortKey)
+	}
+
+	longURL, err := HandleRedirectRequest(ctx, repo, first.ShortKey)
+	if err != nil || longURL != "https://example.com/page" {
+		t.Fatalf("HandleRedirectRequest = %q, %v", longURL, err)
+	}
+	if clicks, _ := repo.ClickCount(first.ShortKey); clicks != 2 {
+		t.Errorf("click count = %d, want 2 (links start at 1)", clicks)
+	}
+
+	if _, err := HandleRedirectRequest(ctx, repo, "AAAAAAA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("HandleRedirectRequest(unknown) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryResolveErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	past := time.Now().Add(-time.Hour)
+
+	repo.Save(ctx, "pending", "https://example.com/a", StatusPending, LinkOptions{})
+	repo.Save(ctx, "expired", "https://example.com/b", StatusActive, LinkOptions{ExpiresAt: &past, ExpiredRedirect: "https://example.com/gone"})
+	repo.Save(ctx, "disabled", "https://example.com/c", StatusActive, LinkOptions{})
+	repo.SetActive("disabled", false)
+
+	if _, _, err := repo.Get(ctx, "pending"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(pending) = %v, want ErrNotFound", err)
+	}
+	var expired *ExpiredError
+	if _, _, err := repo.Get(ctx, "expired"); !errors.As(err, &expired) || expired.ExpiredRedirect != "https://example.com/gone" {
+		t.Errorf("Get(expired) = %v, want an ExpiredError with the replacement destination", err)
+	}
+	if _, _, err := repo.Get(ctx, "disabled"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Get(disabled) = %v, want ErrDisabled", err)
+	}
+	// Pending links are unknown until approved, whatever their other settings
+	repo.Save(ctx, "pendexp", "https://example.com/e", StatusPending, LinkOptions{ExpiresAt: &past, ExpiredRedirect: "https://example.com/gone"})
+	repo.Save(ctx, "penddis", "https://example.com/f", StatusPending, LinkOptions{})
+	repo.SetActive("penddis", false)
+	for _, key := range []string{"pendexp", "penddis"} {
+		if _, _, err := repo.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want ErrNotFound", key, err)
+		}
+	}
+	if err := repo.Save(ctx, "pending", "https://example.com/d", StatusActive, LinkOptions{}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Save(taken key) = %v, want ErrDuplicateKey", err)
+	}
+}
+
+func TestMemoryRepositoryDedupSkipsLinksThatDontStayServable(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	const longURL = "https://example.com/page"
+	future := time.Now().Add(time.Hour)
+
+	limited := createLink(t, repo, longURL, LinkOptions{MaxClicks: 1})
+	if _, err := HandleRedirectRequest(ctx, repo, limited); err != nil {
+		t.Fatalf("HandleRedirectRequest: %v", err)
+	}
+	createLink(t, repo, longURL, LinkOptions{ExpiresAt: &future})
+	createLink(t, repo, longURL, LinkOptions{MaxClicks: 5, ExpiredRedirect: "https://example.com/gone"})
+	disabled := createLink(t, repo, longURL, LinkOptions{})
+	repo.SetActive(disabled, false)
+	pending := "pending"
+	repo.Save(ctx, pending, longURL, StatusPending, LinkOptions{})
+
+	plain, err := HandleShortURLRequest(ctx, longURL, repo, LinkOptions{})
+	if err != nil || !plain.Created {
+		t.Fatalf("plain shorten = %+v, %v, want a new link rather than one of the others", plain, err)
+	}
+	if got, err := HandleRedirectRequest(ctx, repo, plain.ShortKey); err != nil || got != longURL {
+		t.Errorf("plain link redirects to %q, %v", got, err)
+	}
+	again, err := HandleShortURLRequest(ctx, longURL, repo, LinkOptions{})
+	if err != nil || again.Created || again.ShortKey != plain.ShortKey {
+		t.Errorf("second plain shorten = %+v, %v, want %q reused", again, err, plain.ShortKey)
+	}
+
+	// Re-enabling the older plain link makes it the one reused, as ORDER BY id does in Postgres
+	repo.SetActive(disabled, true)
+	if key, _ := repo.FindByLongURL(ctx, longURL); key != disabled {
+		t.Errorf("FindByLongURL = %q, want the oldest plain link %q", key, disabled)
+	}
+}
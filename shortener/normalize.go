@@ -0,0 +1,73 @@
+package shortener
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// StripTrailingSlash makes NormalizeURL drop a trailing slash from non-root paths, so
+// https://example.com/docs/ and https://example.com/docs share a link. It is off by default
+// because some servers treat the two differently.
+var StripTrailingSlash = false
+
+// KeepOriginalURL makes links redirect to the URL exactly as their first creator submitted it,
+// rather than to its normalized form. Links are still deduplicated on the normalized form, so
+// a later request for an equivalent URL gets the existing link, which keeps redirecting to the
+// first-seen spelling. It is off by default; links created while it is on keep their original
+// if it is turned off again.
+var KeepOriginalURL = false
+
+// defaultPorts are the ports implied by each allowed scheme.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL rewrites longURL into a canonical form so equivalent URLs deduplicate to the
+// same link. It lowercases the scheme and host, converts an internationalized host to its
+// Punycode form, drops the scheme's default port, and turns an empty path into "/". With
+// StripTrailingSlash it also removes a trailing slash from the path. The path's case, the
+// query, and the fragment are left alone since they are case-sensitive.
+//
+// URLs that can't be parsed are returned unchanged for validation to reject.
+func NormalizeURL(longURL string) string {
+	parsed, err := url.Parse(longURL)
+	if err != nil || parsed.Host == "" {
+		return longURL
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+
+	host := toASCIIHost(strings.ToLower(parsed.Hostname()))
+	port := parsed.Port()
+	if port == defaultPorts[parsed.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		parsed.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		// IPv6 literals keep their brackets
+		parsed.Host = "[" + host + "]"
+	default:
+		parsed.Host = host
+	}
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+		parsed.RawPath = ""
+	} else if StripTrailingSlash && len(parsed.Path) > 1 {
+		// Trim the escaped form, so an encoded slash (%2F) that ends a segment is kept
+		escaped := strings.TrimRight(parsed.EscapedPath(), "/")
+		if escaped == "" {
+			escaped = "/"
+		}
+		if path, err := url.PathUnescape(escaped); err == nil {
+			parsed.Path = path
+			parsed.RawPath = escaped
+		}
+	}
+
+	return parsed.String()
+}
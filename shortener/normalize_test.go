@@ -0,0 +1,147 @@
+package shortener
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com", "https://example.com/"},
+		{"HTTPS://EXAMPLE.COM/", "https://example.com/"},
+		{"https://Example.COM/Docs/Page", "https://example.com/Docs/Page"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:80/a", "https://example.com:80/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com/docs/", "https://example.com/docs/"},
+		{"https://example.com/search?Q=Go&b=1#Top", "https://example.com/search?Q=Go&b=1#Top"},
+		{"https://[2001:DB8::1]:443/", "https://[2001:db8::1]/"},
+		{"https://[2001:db8::1]:8443/", "https://[2001:db8::1]:8443/"},
+		{"https://bücher.example/", "https://xn--bcher-kva.example/"},
+		{"https://example.com/a%2Fb", "https://example.com/a%2Fb"},
+		// Left for validation to reject
+		{"not a url", "not a url"},
+		{"://missing-scheme", "://missing-scheme"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeURL(tt.in); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeURLStripTrailingSlash(t *testing.T) {
+	old := StripTrailingSlash
+	StripTrailingSlash = true
+	t.Cleanup(func() { StripTrailingSlash = old })
+
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/docs/", "https://example.com/docs"},
+		{"https://example.com/docs//", "https://example.com/docs"},
+		{"https://example.com/", "https://example.com/"},
+		{"https://example.com//", "https://example.com/"},
+		{"https://example.com", "https://example.com/"},
+		{"https://example.com/docs/?page=2", "https://example.com/docs?page=2"},
+		{"https://example.com/a%2F/", "https://example.com/a%2F"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeURL(tt.in); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShortenDeduplicatesNormalizedURLs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	first, err := HandleShortURLRequest(ctx, "https://example.com", repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	for _, variant := range []string{"https://example.com/", "HTTPS://EXAMPLE.COM:443/"} {
+		result, err := HandleShortURLRequest(ctx, variant, repo, LinkOptions{})
+		if err != nil {
+			t.Fatalf("HandleShortURLRequest(%q): %v", variant, err)
+		}
+		if result.ShortKey != first.ShortKey {
+			t.Errorf("HandleShortURLRequest(%q) = %q, want the existing key %q", variant, result.ShortKey, first.ShortKey)
+		}
+	}
+
+	other, err := HandleShortURLRequest(ctx, "https://example.com/Page", repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if other.ShortKey == first.ShortKey {
+		t.Error("a different path reused the same key")
+	}
+}
+
+// useKeepOriginalURL sets KeepOriginalURL for the rest of the test.
+func useKeepOriginalURL(t *testing.T, keep bool) {
+	t.Helper()
+	old := KeepOriginalURL
+	KeepOriginalURL = keep
+	t.Cleanup(func() { KeepOriginalURL = old })
+}
+
+func TestShortenRedirectsToFirstSeenOriginal(t *testing.T) {
+	ctx := context.Background()
+	const first, second = "HTTPS://Example.COM:443/Page", "https://example.com/Page"
+
+	tests := []struct {
+		keep bool
+		want string
+	}{
+		{false, "https://example.com/Page"},
+		{true, first},
+	}
+	for _, tt := range tests {
+		useKeepOriginalURL(t, tt.keep)
+		repo := NewMemoryRepository()
+
+		created := createLink(t, repo, first, LinkOptions{})
+		deduped, err := HandleShortURLRequest(ctx, second, repo, LinkOptions{})
+		if err != nil || deduped.Created || deduped.ShortKey != created {
+			t.Fatalf("keep %v: equivalent URL = %+v, %v, want the existing key %q", tt.keep, deduped, err, created)
+		}
+		if got, err := HandleRedirectRequest(ctx, repo, created); err != nil || got != tt.want {
+			t.Errorf("keep %v: redirect = %q, %v, want %q", tt.keep, got, err, tt.want)
+		}
+		if got, err := LookupLongURL(ctx, repo, created); err != nil || got != tt.want {
+			t.Errorf("keep %v: lookup = %q, %v, want %q", tt.keep, got, err, tt.want)
+		}
+	}
+}
+
+func TestShortenKeepsNoOriginalWhenAlreadyNormal(t *testing.T) {
+	useKeepOriginalURL(t, true)
+	var inserted []driver.NamedValue
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "INSERT INTO urls") {
+			inserted = args
+			return fakeResult{rowsAffected: 1}, nil
+		}
+		return fakeResult{columns: []string{"short_key"}}, nil
+	})
+
+	for _, tt := range []struct{ submitted, wantOriginal string }{
+		{"https://example.com/Page", ""},
+		{"https://Example.com/Page", "https://Example.com/Page"},
+	} {
+		if _, err := HandleShortURLRequest(context.Background(), tt.submitted, NewPostgresRepository(db), LinkOptions{}); err != nil {
+			t.Fatalf("HandleShortURLRequest(%q): %v", tt.submitted, err)
+		}
+		if got := inserted[len(inserted)-1].Value; got != tt.wantOriginal {
+			t.Errorf("HandleShortURLRequest(%q) stored original %q, want %q", tt.submitted, got, tt.wantOriginal)
+		}
+	}
+}
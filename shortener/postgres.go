@@ -0,0 +1,304 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// PostgresRepository is the Repository backed by the urls table in PostgreSQL.
+type PostgresRepository struct {
+	db Querier
+	// inTx is set on the repository handed to InTx callbacks
+	inTx bool
+}
+
+// NewPostgresRepository returns a repository storing links in db, which may be a *sql.Tx to
+// group several operations in one transaction.
+func NewPostgresRepository(db Querier) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// resolvablePredicate restricts a query on urls to links that currently redirect.
+// The click limit check makes the guarded UPDATEs atomic: of concurrent clicks on a link
+// with one use left, only the first to take the row lock still matches.
+const resolvablePredicate = `status = '` + StatusActive + `' AND is_active AND (expires_at IS NULL OR expires_at > now())
+        AND (max_clicks IS NULL OR click_count < max_clicks)`
+
+// redirectColumn is where a link sends visitors: the URL as first submitted when it was kept
+// (see KeepOriginalURL), and the normalized long URL otherwise.
+const redirectColumn = `COALESCE(original_url, long_url)`
+
+// FindByLongURL looks up an existing link for longURL. Inside InTx it first takes a
+// transaction-scoped advisory lock on the URL, so concurrent creators of the same URL
+// serialize and the second one finds the first one's link.
+func (p *PostgresRepository) FindByLongURL(ctx context.Context, longURL string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "urls.find_by_long_url")
+	defer func() { endSpan(span, err) }()
+
+	if p.inTx {
+		lockCtx, cancel := withQueryTimeout(ctx)
+		defer cancel()
+		// The two-key form keeps these locks apart from single-key advisory locks
+		if _, err := p.db.ExecContext(lockCtx, `SELECT pg_advisory_xact_lock(1, hashtext($1))`, longURL); err != nil {
+			return "", fmt.Errorf("failed to lock long URL: %w", err)
+		}
+	}
+	return CheckDbForLongURL(ctx, p.db, longURL)
+}
+
+func (p *PostgresRepository) Save(ctx context.Context, shortKey, longURL, status string, opts LinkOptions) (err error) {
+	ctx, span := startSpan(ctx, "urls.insert")
+	defer func() { endSpan(span, err) }()
+
+	return saveURLToDatabase(ctx, p.db, shortKey, longURL, status, opts)
+}
+
+// InTx runs fn in a transaction. If the repository is already inside one, or its Querier
+// can't begin transactions, fn runs directly on it.
+func (p *PostgresRepository) InTx(ctx context.Context, fn func(repo Repository) error) error {
+	db, ok := p.db.(TxBeginner)
+	if !ok || p.inTx {
+		return fn(p)
+	}
+	return WithTx(ctx, db, func(tx *sql.Tx) error {
+		return fn(&PostgresRepository{db: tx, inTx: true})
+	})
+}
+
+// IncrementAndGet increments the click counter and returns the redirect target (redirectColumn)
+// in a single UPDATE ... RETURNING.
+func (p *PostgresRepository) IncrementAndGet(ctx context.Context, shortKey string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "urls.increment_and_get")
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var longURL string
+	query := `
+        UPDATE urls
+        SET click_count = click_count + 1
+        WHERE short_key = $1 AND ` + resolvablePredicate + `
+        RETURNING ` + redirectColumn + `
+    `
+
+	err = p.db.QueryRowContext(ctx, query, shortKey).Scan(&longURL)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", classifyMissingLink(ctx, p.db, shortKey)
+		}
+		return "", fmt.Errorf("database query failed: %w", err)
+	}
+	recordClick(ctx, p.db, shortKey)
+
+	return longURL, nil
+}
+
+func (p *PostgresRepository) Increment(ctx context.Context, shortKey string) (err error) {
+	ctx, span := startSpan(ctx, "urls.increment")
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+        UPDATE urls
+        SET click_count = click_count + 1
+        WHERE short_key = $1 AND ` + resolvablePredicate
+
+	result, err := p.db.ExecContext(ctx, query, shortKey)
+	if err != nil {
+		return fmt.Errorf("database query failed: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database query failed: %w", err)
+	}
+	if rows == 0 {
+		return classifyMissingLink(ctx, p.db, shortKey)
+	}
+	recordClick(ctx, p.db, shortKey)
+
+	return nil
+}
+
+func (p *PostgresRepository) Get(ctx context.Context, shortKey string) (_ string, _ bool, err error) {
+	ctx, span := startSpan(ctx, "urls.get")
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var longURL string
+	var limited bool
+	query := `SELECT ` + redirectColumn + `, max_clicks IS NOT NULL FROM urls WHERE short_key = $1 AND ` + resolvablePredicate
+
+	err = p.db.QueryRowContext(ctx, query, shortKey).Scan(&longURL, &limited)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, classifyMissingLink(ctx, p.db, shortKey)
+		}
+		return "", false, fmt.Errorf("database query failed: %w", err)
+	}
+
+	return longURL, limited, nil
+}
+
+// NextID returns the next value of the short_key_seq sequence.
+func (p *PostgresRepository) NextID(ctx context.Context) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "urls.next_id")
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	if err := p.db.QueryRowContext(ctx, `SELECT nextval('short_key_seq')`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get next key sequence value: %w", err)
+	}
+	return id, nil
+}
+
+// CheckDbForLongURL queries the database for an existing long URL.
+// If found, it returns the associated short key.
+// If not found, it returns an empty string and no error.
+// If a database error occurs, it returns an empty string and the error.
+//
+// Only plain links are reused: approved, enabled, and without an expiry, click limit, or
+// replacement destination. A link that is pending, disabled, or could run out would
+// otherwise be handed to a caller who asked for none of that.
+func CheckDbForLongURL(ctx context.Context, db Querier, longURL string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var shortKey string
+	query := `
+        SELECT short_key FROM urls
+        WHERE long_url = $1 AND status = '` + StatusActive + `' AND is_active
+          AND expires_at IS NULL AND max_clicks IS NULL AND expired_redirect IS NULL
+        ORDER BY id
+        LIMIT 1
+    `
+
+	// QueryRowContext is used because we expect at most one result.
+	err := db.QueryRowContext(ctx, query, longURL).Scan(&shortKey)
+	if err != nil {
+		// If no rows are found, it's not an application error.
+		// It simply means the URL isn't in the database yet.
+		if err == sql.ErrNoRows {
+			return "", nil // Return empty string and nil error as requested.
+		}
+		// For any other error, wrap it and return for the caller to handle.
+		return "", fmt.Errorf("error querying database for long URL: %w", err)
+	}
+
+	return shortKey, nil
+}
+
+// saveURLToDatabase inserts a new URL mapping into the database.
+//
+// It stores the short key and its corresponding long URL in the urls table with the given
+// status. If a collision occurs (the short key already exists), it returns ErrDuplicateKey.
+//
+// Parameters:
+//   - ctx: Context for controlling the database operation lifecycle
+//   - db: Database connection pool
+//   - shortKey: The generated short identifier for the URL
+//   - longURL: The original long URL to be shortened
+//   - status: StatusActive, or StatusPending for links awaiting approval
+//   - opts: Per-link settings to store with the mapping
+//
+// Returns:
+//   - nil on success
+//   - ErrDuplicateKey if the short key already exists (collision)
+//   - a wrapped error if the database insert fails
+func saveURLToDatabase(ctx context.Context, db Querier, shortKey, longURL, status string, opts LinkOptions) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+        INSERT INTO urls (short_key, long_url, status, expires_at, expired_redirect, created_by, max_clicks, click_count, original_url)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7::integer, 0), CASE WHEN $7::integer > 0 THEN 0 ELSE 1 END, NULLIF($8, ''))
+        ON CONFLICT (short_key) DO NOTHING
+    `
+
+	// ON CONFLICT reports collisions without aborting an enclosing transaction, which a
+	// unique violation would, so the retry can run in the same transaction. Limited links
+	// start at 0 clicks so max_clicks counts real visits; others keep the historical 1.
+	result, err := db.ExecContext(ctx, query, shortKey, longURL, status, opts.ExpiresAt, opts.ExpiredRedirect,
+		opts.CreatedBy, opts.MaxClicks, opts.OriginalURL)
+	if err != nil {
+		if isCollisionError(err) {
+			return ErrDuplicateKey
+		}
+		return fmt.Errorf("database insert failed: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database insert failed: %w", err)
+	}
+	if rows == 0 {
+		return ErrDuplicateKey
+	}
+
+	return nil
+}
+
+// IsTimeout reports whether err comes from a database call that ran out of time, either
+// through its context deadline or because Postgres cancelled the statement.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
+
+// isCollisionError checks if the provided error is a PostgreSQL unique constraint violation error (code "23505").
+// It returns true if the error indicates a collision (e.g., duplicate key), and false otherwise.
+// It is only used to map driver errors to ErrDuplicateKey.
+func isCollisionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// classifyMissingLink explains why a redirect lookup matched no row. It returns ErrDisabled
+// when a link exists for shortKey but has been disabled, an *ExpiredError (matching
+// ErrExpired) when it has passed its expiry or used up its click limit, and ErrNotFound
+// otherwise. Links awaiting approval are always ErrNotFound, so their expiry settings don't
+// reveal that they exist. This only runs on the miss path, so successful redirects still take
+// a single query.
+func classifyMissingLink(ctx context.Context, db Querier, shortKey string) error {
+	var status string
+	var active, expired bool
+	var expiredRedirect sql.NullString
+	query := `
+        SELECT status, is_active,
+               (expires_at IS NOT NULL AND expires_at <= now()) OR (max_clicks IS NOT NULL AND click_count >= max_clicks),
+               expired_redirect
+        FROM urls
+        WHERE short_key = $1
+    `
+
+	err := db.QueryRowContext(ctx, query, shortKey).Scan(&status, &active, &expired, &expiredRedirect)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("database query failed: %w", err)
+	}
+
+	if status != StatusActive {
+		return ErrNotFound
+	}
+	if !active {
+		return ErrDisabled
+	}
+	if expired {
+		return &ExpiredError{ExpiredRedirect: expiredRedirect.String}
+	}
+	return ErrNotFound
+}
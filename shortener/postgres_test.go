@@ -0,0 +1,225 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+var _ Repository = (*PostgresRepository)(nil)
+
+// collidingDB returns a fake database whose first collisions inserts hit a taken key, and
+// whose inserts after that succeed. Every key tried is appended to tried.
+func collidingDB(t *testing.T, collisions int, tried *[]string) Querier {
+	return newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		switch {
+		case strings.Contains(query, "INSERT INTO urls"):
+			*tried = append(*tried, args[0].Value.(string))
+			if len(*tried) <= collisions {
+				return fakeResult{rowsAffected: 0}, nil
+			}
+			return fakeResult{rowsAffected: 1}, nil
+		case strings.Contains(query, "SELECT short_key FROM urls"):
+			return fakeResult{columns: []string{"short_key"}}, nil
+		default:
+			return fakeResult{}, nil
+		}
+	})
+}
+
+func TestShortenRetriesKeyCollisions(t *testing.T) {
+	useStrategy(t, HashStrategy{})
+	const longURL = "https://example.com/page"
+	var tried []string
+	repo := NewPostgresRepository(collidingDB(t, 2, &tried))
+
+	result, err := HandleShortURLRequest(context.Background(), longURL, repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if len(tried) != 3 {
+		t.Fatalf("tried %d keys, want 3 (two collisions, then success)", len(tried))
+	}
+	for attempt, key := range tried {
+		if want := generateShortURLKey(longURL, attempt); key != want {
+			t.Errorf("attempt %d tried %q, want the key salted with %d (%q)", attempt, key, attempt, want)
+		}
+	}
+	if !result.Created || result.ShortKey != tried[2] {
+		t.Errorf("result = %+v, want the created key %q", result, tried[2])
+	}
+}
+
+func TestShortenGivesUpAfterMaxRetries(t *testing.T) {
+	useStrategy(t, HashStrategy{})
+	var tried []string
+	repo := NewPostgresRepository(collidingDB(t, MaxRetries, &tried))
+
+	_, err := HandleShortURLRequest(context.Background(), "https://example.com/page", repo, LinkOptions{})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("HandleShortURLRequest = %v, want ErrDuplicateKey once retries run out", err)
+	}
+	if len(tried) != MaxRetries {
+		t.Errorf("tried %d keys, want MaxRetries (%d)", len(tried), MaxRetries)
+	}
+}
+
+func TestShortenCollisionErrors(t *testing.T) {
+	useStrategy(t, HashStrategy{})
+	tests := []struct {
+		name      string
+		insertErr error
+		wantTries int
+	}{
+		{"unique violation is retried", &pq.Error{Code: "23505"}, 2},
+		{"other errors fail immediately", errors.New("connection reset"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []string
+			// Fail the first insert with insertErr, then succeed
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+				if !strings.Contains(query, "INSERT INTO urls") {
+					return fakeResult{columns: []string{"short_key"}}, nil
+				}
+				tried = append(tried, args[0].Value.(string))
+				if len(tried) == 1 {
+					return fakeResult{}, tt.insertErr
+				}
+				return fakeResult{rowsAffected: 1}, nil
+			})
+
+			_, err := HandleShortURLRequest(context.Background(), "https://example.com/page", NewPostgresRepository(db), LinkOptions{})
+			if len(tried) != tt.wantTries {
+				t.Errorf("tried %d keys, want %d", len(tried), tt.wantTries)
+			}
+			if tt.wantTries == 1 && err == nil {
+				t.Error("HandleShortURLRequest succeeded, want the insert error")
+			}
+			if tt.wantTries > 1 && err != nil {
+				t.Errorf("HandleShortURLRequest: %v", err)
+			}
+		})
+	}
+}
+
+// contextQuerier records the context of every call before passing it on.
+type contextQuerier struct {
+	Querier
+	contexts []context.Context
+}
+
+func (q *contextQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	q.contexts = append(q.contexts, ctx)
+	return q.Querier.ExecContext(ctx, query, args...)
+}
+
+func (q *contextQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	q.contexts = append(q.contexts, ctx)
+	return q.Querier.QueryRowContext(ctx, query, args...)
+}
+
+func TestShortenPassesContextToDatabase(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	var tried []string
+	db := &contextQuerier{Querier: collidingDB(t, 0, &tried)}
+
+	if _, err := HandleShortURLRequest(ctx, "https://example.com/page", NewPostgresRepository(db), LinkOptions{}); err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if len(db.contexts) != 2 {
+		t.Fatalf("made %d database calls, want the lookup and the insert", len(db.contexts))
+	}
+	for i, callCtx := range db.contexts {
+		if callCtx.Value(ctxKey{}) != "request" {
+			t.Errorf("database call %d didn't get the request context", i)
+		}
+	}
+}
+
+func TestShortenStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var inserts int
+	// The client goes away during the dedup lookup
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		if strings.Contains(query, "INSERT INTO urls") {
+			inserts++
+			return fakeResult{rowsAffected: 1}, nil
+		}
+		cancel()
+		return fakeResult{columns: []string{"short_key"}}, nil
+	})
+
+	if _, err := HandleShortURLRequest(ctx, "https://example.com/page", NewPostgresRepository(db), LinkOptions{}); err == nil {
+		t.Error("HandleShortURLRequest succeeded after the request was cancelled")
+	}
+	if inserts != 0 {
+		t.Errorf("inserted %d links after the request was cancelled, want 0", inserts)
+	}
+}
+
+func TestClassifyMissingLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		row          []driver.Value
+		wantErr      error
+		wantRedirect string
+	}{
+		{"no row", nil, ErrNotFound, ""},
+		{"disabled", []driver.Value{StatusActive, false, false, nil}, ErrDisabled, ""},
+		{"expired", []driver.Value{StatusActive, true, true, "https://example.com/gone"}, ErrExpired, "https://example.com/gone"},
+		{"pending and expired", []driver.Value{StatusPending, true, true, "https://example.com/gone"}, ErrNotFound, ""},
+		{"pending and disabled", []driver.Value{StatusPending, false, false, nil}, ErrNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+				result := fakeResult{columns: []string{"status", "is_active", "expired", "expired_redirect"}}
+				if tt.row != nil {
+					result.rows = [][]driver.Value{tt.row}
+				}
+				return result, nil
+			})
+
+			err := classifyMissingLink(context.Background(), db, "abcdefg")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("classifyMissingLink = %v, want %v", err, tt.wantErr)
+			}
+			var expired *ExpiredError
+			if errors.As(err, &expired) && expired.ExpiredRedirect != tt.wantRedirect {
+				t.Errorf("expired redirect = %q, want %q", expired.ExpiredRedirect, tt.wantRedirect)
+			}
+		})
+	}
+}
+
+func TestCheckDbForLongURLOnlyReusesPlainLinks(t *testing.T) {
+	var query string
+	db := newFakeDB(t, func(q string, args []driver.NamedValue) (fakeResult, error) {
+		query = q
+		return fakeResult{columns: []string{"short_key"}}, nil
+	})
+	if _, err := CheckDbForLongURL(context.Background(), db, "https://example.com/"); err != nil {
+		t.Fatalf("CheckDbForLongURL: %v", err)
+	}
+
+	// Each of these would hand a plain shorten a link that doesn't resolve or can stop
+	// resolving; the Postgres integration tests check the query's behavior
+	for _, predicate := range []string{
+		"status = '" + StatusActive + "'",
+		"is_active",
+		"expires_at IS NULL",
+		"max_clicks IS NULL",
+		"expired_redirect IS NULL",
+	} {
+		if !strings.Contains(query, predicate) {
+			t.Errorf("dedup query doesn't require %s:\n%s", predicate, query)
+		}
+	}
+}
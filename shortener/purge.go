@@ -0,0 +1,89 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultPurgeBatchSize is how many expired links PurgeExpired deletes per statement.
+const DefaultPurgeBatchSize = 1000
+
+// PurgeExpired deletes links whose expiry passed more than grace ago, along with their daily
+// click rollups and click events, and returns how many links were removed. It deletes at
+// most batchSize rows per statement and loops until none are left, so a large backlog never
+// holds locks on the table for long. Purged keys are evicted from RedirectCache.
+//
+// Once a link is purged it returns 404 instead of 410 or its expired_redirect, so grace
+// controls how long expired links keep answering before they go away.
+func PurgeExpired(ctx context.Context, db Querier, grace time.Duration, batchSize int) (int64, error) {
+	query := `
+        WITH purged AS (
+            DELETE FROM urls
+            WHERE id IN (
+                SELECT id FROM urls
+                WHERE expires_at < now() - $1::interval
+                LIMIT $2
+            )
+            RETURNING short_key
+        ), rollups AS (
+            DELETE FROM url_clicks_daily WHERE short_key IN (SELECT short_key FROM purged)
+        ), events AS (
+            DELETE FROM click_events WHERE short_key IN (SELECT short_key FROM purged)
+        )
+        SELECT array_agg(short_key) FROM purged
+    `
+	interval := fmt.Sprintf("%d microseconds", grace.Microseconds())
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		var keys []string
+		queryCtx, cancel := withQueryTimeout(ctx)
+		err := db.QueryRowContext(queryCtx, query, interval, batchSize).Scan(pq.Array(&keys))
+		cancel()
+		if err != nil {
+			return total, fmt.Errorf("database delete failed: %w", err)
+		}
+		for _, shortKey := range keys {
+			if RedirectCache != nil {
+				RedirectCache.Delete(ctx, shortKey)
+			}
+			audit(AuditDelete, shortKey, AuditActorPurge)
+		}
+
+		total += int64(len(keys))
+		if len(keys) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunExpiredPurge calls PurgeExpired every interval until ctx is cancelled, logging how many
+// links each run removed. Failures are logged and retried on the next run.
+func RunExpiredPurge(ctx context.Context, db Querier, interval, grace time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := PurgeExpired(ctx, db, grace, batchSize)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("failed to purge expired links", "purged", purged, "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("purged expired links", "purged", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package shortener
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurgeExpiredDeletesLinkData(t *testing.T) {
+	var queries []string
+	batches := []string{"{aaaaaaa,bbbbbbb}", "{ccccccc}"}
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		queries = append(queries, query)
+		keys := batches[0]
+		batches = batches[1:]
+		return fakeResult{columns: []string{"array_agg"}, rows: [][]driver.Value{{keys}}}, nil
+	})
+
+	purged, err := PurgeExpired(context.Background(), db, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if purged != 3 {
+		t.Errorf("purged %d links, want 3", purged)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("ran %d statements, want one per batch until a short batch (2)", len(queries))
+	}
+	// Keys are deterministic, so anything left behind would be attributed to the next link
+	// created with the same key
+	for _, table := range []string{"urls", "url_clicks_daily", "click_events"} {
+		if !strings.Contains(queries[0], "DELETE FROM "+table) {
+			t.Errorf("purge statement doesn't delete from %s", table)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// CheckReachability enables probing long URLs before they are shortened, so links to
+	// pages that are down or missing are rejected. It is off by default because it adds a
+	// round trip to the destination to every shorten request.
+	CheckReachability = false
+	// ReachabilityTimeout bounds each probe, including any redirects it follows.
+	ReachabilityTimeout = 5 * time.Second
+)
+
+// MaxProbeRedirects bounds how many redirects a reachability probe follows.
+const MaxProbeRedirects = 5
+
+// errBlockedAddress is returned by the probe dialer for connections to blocked addresses.
+var errBlockedAddress = errors.New("connection to internal or private address refused")
+
+// probeClient makes reachability probes. It checks the address of every connection it makes
+// rather than the hostname, so DNS rebinding can't point a probe at an internal host, and
+// it validates every redirect target like a long URL.
+var probeClient = &http.Client{
+	Transport: &http.Transport{
+		// Always connect directly, so the dialer sees the real destination address
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				addr, err := netip.ParseAddr(host)
+				if err != nil || isBlockedAddr(addr) {
+					return errBlockedAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConns:    10,
+		IdleConnTimeout: 30 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > MaxProbeRedirects {
+			return fmt.Errorf("more than %d redirects", MaxProbeRedirects)
+		}
+		for _, check := range []urlCheck{checkScheme, checkUserinfo, checkHost} {
+			if err := check(req.URL); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// checkReachable probes longURL when CheckReachability is enabled, failing if it can't be
+// reached or answers with a 4xx or 5xx status. It sends a HEAD request, falling back to GET
+// for servers that don't support HEAD.
+//
+// Returns an error wrapping ErrInvalidURL if the URL is unreachable.
+func checkReachable(ctx context.Context, longURL string) error {
+	if !CheckReachability {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ReachabilityTimeout)
+	defer cancel()
+
+	status, err := probe(ctx, http.MethodHead, longURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = probe(ctx, http.MethodGet, longURL)
+	}
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// The cause alone, without repeating the method and URL
+			err = urlErr.Err
+		}
+		// Formatted rather than wrapped, so a slow destination is reported as invalid and not
+		// as a timeout of this service
+		return invalidURLf("url is not reachable: %v", err)
+	}
+	if status >= 400 {
+		return invalidURLf("url is not reachable: status %d", status)
+	}
+	return nil
+}
+
+// probe sends a single request for rawURL and returns the final response status.
+func probe(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// The body isn't needed, and isn't drained so a GET doesn't download the whole page
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
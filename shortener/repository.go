@@ -0,0 +1,42 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is the subset of *sql.DB the shortener functions use to talk to the database.
+// *sql.DB, *sql.Tx, and *sql.Conn all satisfy it, so the same functions can run inside a
+// transaction, and tests can substitute a fake.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Repository is the storage behind link creation and redirects. PostgresRepository is the
+// production implementation; others (in-memory, Redis) can be plugged in, for tests in
+// particular. Implementations must be safe for concurrent use.
+//
+// Lookups only see links that resolve: active, not disabled, not past their expiry, and under
+// their click limit. For links that exist but don't resolve, they return ErrDisabled if the
+// link has been disabled, an *ExpiredError if it has expired or used up its clicks, and
+// ErrNotFound otherwise.
+type Repository interface {
+	// FindByLongURL returns the short key of an existing link for longURL, or "" if there is none.
+	FindByLongURL(ctx context.Context, longURL string) (string, error)
+	// Save stores a new link with the given status. It returns ErrDuplicateKey if shortKey is
+	// already taken, which HandleShortURLRequest retries with a new key.
+	Save(ctx context.Context, shortKey, longURL, status string, opts LinkOptions) error
+	// IncrementAndGet atomically counts a click for shortKey and returns its long URL. A link
+	// with a click limit must never be counted past it, even under concurrent calls.
+	IncrementAndGet(ctx context.Context, shortKey string) (string, error)
+	// Increment counts a click for shortKey, whose long URL the caller already has, with the
+	// same guarantee about click limits.
+	Increment(ctx context.Context, shortKey string) error
+	// Get returns the long URL for shortKey without counting a click, and whether the link
+	// has a click limit, in which case clicks must be counted with Increment before redirecting.
+	Get(ctx context.Context, shortKey string) (longURL string, limited bool, err error)
+	// NextID returns a new, never before returned ID, for SequentialStrategy.
+	NextID(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,95 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers lookups from a fixed table and counts them.
+type fakeResolver struct {
+	addrs   map[string][]string
+	lookups int
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups++
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("lookup without a deadline")
+	}
+	ips, ok := r.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+// useResolver enables ResolveHosts with resolver for the rest of the test.
+func useResolver(t *testing.T, resolver Resolver) {
+	t.Helper()
+	oldEnabled, oldResolver := ResolveHosts, HostResolver
+	ResolveHosts, HostResolver = true, resolver
+	t.Cleanup(func() { ResolveHosts, HostResolver = oldEnabled, oldResolver })
+}
+
+func TestValidateLongURLResolvesHosts(t *testing.T) {
+	resolver := &fakeResolver{addrs: map[string][]string{
+		"public.example":    {"93.184.216.34", "2606:2800:220:1::1"},
+		"internal.example":  {"10.1.2.3"},
+		"mixed.example":     {"93.184.216.34", "192.168.0.10"},
+		"loopback6.example": {"::1"},
+		"metadata.example":  {"169.254.169.254"},
+	}}
+	useResolver(t, resolver)
+
+	tests := []struct {
+		url, err string
+	}{
+		{"https://public.example/", ""},
+		{"https://internal.example/", "private"},
+		// One private answer is enough, since the client may connect to any of them
+		{"https://mixed.example/", "private"},
+		{"https://loopback6.example/", "private"},
+		{"https://metadata.example/", "private"},
+		{"https://missing.example/", "could not resolve"},
+	}
+	for _, tt := range tests {
+		err := ValidateLongURL(tt.url)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("ValidateLongURL(%q) = %v, want nil", tt.url, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidURL) || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ValidateLongURL(%q) = %v, want an ErrInvalidURL mentioning %q", tt.url, err, tt.err)
+		}
+	}
+
+	// IP literals are checked without a lookup
+	resolver.lookups = 0
+	ValidateLongURL("https://93.184.216.34/")
+	ValidateLongURL("https://[2606:2800:220:1::1]/")
+	if resolver.lookups != 0 {
+		t.Errorf("IP literals made %d lookups, want none", resolver.lookups)
+	}
+}
+
+func TestResolveHostsOffByDefault(t *testing.T) {
+	resolver := &fakeResolver{}
+	useResolver(t, resolver)
+	ResolveHosts = false
+
+	if err := ValidateLongURL("https://internal.example/"); err != nil {
+		t.Errorf("ValidateLongURL = %v, want nil without resolving", err)
+	}
+	if resolver.lookups != 0 {
+		t.Errorf("made %d lookups with ResolveHosts off, want none", resolver.lookups)
+	}
+}
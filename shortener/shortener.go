@@ -2,128 +2,324 @@ package shortener
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/url"
-	"strings"
-
-	"github.com/lib/pq"
+	"time"
 )
 
 const (
-	// Conservative limit from broad compatibility, can be configured to 8192 or higher
-	// based on client needs and server configuration
-	MaxURLLength = 2048
+	// DefaultMaxURLLength is a conservative limit for broad compatibility, used unless
+	// SetMaxURLLength raises it (8192 or higher, based on client needs and server configuration)
+	DefaultMaxURLLength = 2048
+	// UpperMaxURLLength bounds SetMaxURLLength. Longer URLs aren't reliably handled by browsers
+	// and servers, and each one is stored and matched against the denylist
+	UpperMaxURLLength = 65536
 	// Max retries in the case of collisions or server issues
 	MaxRetries = 5
+
+	// StatusActive marks a link that resolves normally
+	StatusActive = "active"
+	// StatusPending marks a link that is waiting for admin approval and does not resolve yet
+	StatusPending = "pending"
 )
 
-// ValidateLongURL checks whether the provided longURL is a valid and safe URL for use in the URL shortener service.
-// It performs the following validations:
-//   - Ensures the URL does not exceed 2048 characters.
-//   - Checks that the URL is properly formatted and parsable.
-//   - Verifies that the URL uses either the "http" or "https" scheme.
-//   - Prevents Server-Side Request Forgery (SSRF) by disallowing URLs pointing to localhost, 127.0.0.1, or 0.0.0.0.
-//
-// Returns an error if any validation fails, or nil if the URL is valid.
-func ValidateLongURL(longURL string) error {
-	// Length check - prevent extremely long URLs
-	if len(longURL) > MaxURLLength {
-		return fmt.Errorf("url exceeds maximum length of %d characters", MaxURLLength)
-	}
+const (
+	// DefaultKeyLength is the short key length used unless SetKeyLength is called
+	DefaultKeyLength = 7
+	// MinKeyLength keeps the keyspace from becoming small enough to enumerate or exhaust
+	MinKeyLength = 4
+	// MaxKeyLength is the number of unpadded base64 characters in a SHA256 digest
+	MaxKeyLength = 43
+)
 
-	// Validate URL structure
-	parsedURL, err := url.Parse(longURL)
-	if err != nil {
-		return fmt.Errorf("invalid url format %w", err)
-	}
+// KeyLength is the number of characters in generated short keys. Both key generation and
+// redirect lookups read it, so it must only be changed through SetKeyLength at startup.
+var KeyLength = DefaultKeyLength
 
-	// Check if longURL has a valid scheme for XSS protection
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return fmt.Errorf("URL must use http or https scheme")
+// SetKeyLength sets the length of generated short keys, rejecting lengths outside
+// MinKeyLength and MaxKeyLength. Existing links keep working only if their keys have the
+// new length, so changing it on a populated database orphans the old links.
+func SetKeyLength(length int) error {
+	if length < MinKeyLength || length > MaxKeyLength {
+		return fmt.Errorf("key length must be between %d and %d, got %d", MinKeyLength, MaxKeyLength, length)
 	}
+	KeyLength = length
+	return nil
+}
+
+// MaxURLLength is the longest long URL accepted, in bytes. It must only be changed through
+// SetMaxURLLength at startup.
+var MaxURLLength = DefaultMaxURLLength
 
-	// SSRF Protection
-	host := strings.ToLower(parsedURL.Hostname())
-	if host == "localhost" ||
-		host == "127.0.0.1" ||
-		host == "0.0.0.0" ||
-		host == "::1" || // IPv6 localhost
-		strings.HasPrefix(host, "127.") || // Entire 127.x.x.x range
-		strings.HasPrefix(host, "10.") || // Private network
-		strings.HasPrefix(host, "192.168.") || // Private network
-		strings.HasPrefix(host, "172.16.") { // Private network (simplified)
-		return fmt.Errorf("internal or private URLs are not allowed")
+// SetMaxURLLength sets the longest long URL accepted, rejecting lengths that aren't positive
+// or exceed UpperMaxURLLength. Lowering it doesn't affect links that already exist.
+func SetMaxURLLength(length int) error {
+	if length <= 0 || length > UpperMaxURLLength {
+		return fmt.Errorf("max URL length must be between 1 and %d, got %d", UpperMaxURLLength, length)
 	}
+	MaxURLLength = length
+	return nil
+}
 
+// RequireApproval controls whether newly created links start in the pending state.
+// When enabled, links only resolve after an admin approves them with ApproveShortURL.
+var RequireApproval = false
+
+// LinkOptions are optional per-link settings supplied when a link is created.
+type LinkOptions struct {
+	// ExpiresAt is when the link stops resolving, nil for never
+	ExpiresAt *time.Time
+	// ExpiredRedirect is where the link sends visitors once it has expired, instead of a 410
+	ExpiredRedirect string
+	// CreatedBy names the API key that created the link, "" when unauthenticated
+	CreatedBy string
+	// MaxClicks is how many redirects the link serves before it is used up, 0 for unlimited.
+	// Use 1 for single-use links.
+	MaxClicks int
+	// OriginalURL is the URL as submitted, which the link redirects to instead of the
+	// normalized long URL. HandleShortURLRequest sets it when KeepOriginalURL is on and
+	// normalization changed the URL.
+	OriginalURL string
+}
+
+// isZero reports whether no per-link settings that change how the link behaves were given.
+// CreatedBy is attribution only and doesn't count, and neither does OriginalURL, since links
+// are deduplicated on the normalized URL.
+func (o LinkOptions) isZero() bool {
+	return o.ExpiresAt == nil && o.ExpiredRedirect == "" && o.MaxClicks == 0
+}
+
+// validate checks the options the same way as the long URL they apply to.
+func (o LinkOptions) validate() error {
+	if o.ExpiresAt != nil && !o.ExpiresAt.After(time.Now()) {
+		return invalidURLf("expires_at must be in the future")
+	}
+	if o.MaxClicks < 0 {
+		return invalidURLf("max_clicks must not be negative")
+	}
+	if o.ExpiredRedirect != "" {
+		if o.ExpiresAt == nil && o.MaxClicks == 0 {
+			return invalidURLf("expired_redirect requires expires_at or max_clicks")
+		}
+		if err := ValidateLongURL(o.ExpiredRedirect); err != nil {
+			return invalidURLf("invalid expired_redirect: %w", err)
+		}
+	}
 	return nil
 }
 
+// ShortenResult is the link returned by HandleShortURLRequest.
+type ShortenResult struct {
+	// ShortKey is the link's key, for clients building URLs on their own domains
+	ShortKey string
+	// ShortURL is the full short URL on BaseURL
+	ShortURL string
+	// Created is false when an existing link for the same URL was returned
+	Created bool
+	// LongURL is the URL the link is stored and deduplicated under, after normalization
+	LongURL string
+}
+
 // HandleShortURLRequest processes a long URL by validating it, checking for an existing shortened version in the database,
 // and generating the full shortened URL if it exists. It helps prevent XSS and SSRF attacks by validating the input.
 // If the long URL has already been shortened, it returns the existing shortened URL. Otherwise, it returns an error.
+// The URL is normalized with NormalizeURL before the dedup lookup and the insert. With
+// KeepOriginalURL, a new link also stores the URL as submitted and redirects there.
+// New keys come from the configured Strategy. In KnownShortenersResolve mode, links on other
+// shorteners are first resolved to their destination. With CheckReachability enabled, the
+// URL is probed first and rejected if it can't be reached.
+//
+// Links created with per-link options are never deduplicated, since an existing link for the
+// same URL may carry different settings.
 //
 // Parameters:
+//   - ctx: Context for the request, cancelling it aborts the database lookup and insert.
 //   - longUrl: The original URL to be shortened.
-//   - db: A pointer to the SQL database connection.
+//   - repo: Storage the link is saved in.
+//   - opts: Optional per-link settings such as expiry.
 //
 // Returns:
-//   - string: The full shortened URL if found.
-//   - error: An error if validation fails, the database lookup fails, or the shortened URL cannot be constructed.
-func HandleShortURLRequest(longUrl string, db *sql.DB) (string, error) {
+//   - ShortenResult: The key and full shortened URL, and whether a new link was created.
+//   - error: An error if validation fails, the database lookup fails, the creation cap has been reached
+//     (ErrCapacityReached), or the shortened URL cannot be constructed.
+func HandleShortURLRequest(ctx context.Context, longUrl string, repo Repository, opts LinkOptions) (ShortenResult, error) {
+	// Shorten the real destination rather than chaining onto another shortener's link
+	longUrl, resolveErr := resolveKnownShortener(ctx, longUrl)
+	if resolveErr != nil {
+		return ShortenResult{}, fmt.Errorf("validation failed: %w", resolveErr)
+	}
+
+	// Canonicalize so equivalent spellings of a URL dedup to one link
+	submitted := longUrl
+	longUrl = NormalizeURL(longUrl)
+	if KeepOriginalURL && submitted != longUrl {
+		// Only the normalized form is validated. The original differs from it in spelling
+		// alone, but normalization can shorten it
+		if len(submitted) > MaxURLLength {
+			return ShortenResult{}, fmt.Errorf("validation failed: %w", invalidURLf("url exceeds maximum length of %d characters", MaxURLLength))
+		}
+		opts.OriginalURL = submitted
+	}
+
 	// Validate the input to catch and prevent XSS and SSRF attacks
 	if err := ValidateLongURL(longUrl); err != nil {
-		return "", fmt.Errorf("validation failed: %w", err)
+		return ShortenResult{}, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := opts.validate(); err != nil {
+		return ShortenResult{}, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := checkReachable(ctx, longUrl); err != nil {
+		return ShortenResult{}, fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Check if the longURL has already been shortened (dedup)
-	shortKey, err := CheckDbForLongURL(context.Background(), db, longUrl)
+	// The dedup lookup and the insert share a transaction, so concurrent requests for the same
+	// URL can't both miss the lookup and create two links
+	var shortKey string
+	var created bool
+	err := runInTx(ctx, repo, func(repo Repository) error {
+		var err error
+		shortKey, created, err = findOrCreateLink(ctx, repo, longUrl, opts)
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("database lookup failed: %w", err)
+		if created {
+			// The link was inserted but the transaction didn't commit
+			CreationCap.Release()
+		}
+		return ShortenResult{}, err
+	}
+	if created {
+		audit(AuditCreate, shortKey, opts.CreatedBy)
+	}
+
+	shortURL, err := GenerateFullShortURL(shortKey)
+	if err != nil {
+		return ShortenResult{}, err
+	}
+	return ShortenResult{ShortKey: shortKey, ShortURL: shortURL, Created: created, LongURL: longUrl}, nil
+}
+
+// findOrCreateLink returns the key of an existing link for longUrl, or saves a new one and
+// reports created. New links are reserved against CreationCap, which is released again if
+// saving fails.
+func findOrCreateLink(ctx context.Context, repo Repository, longUrl string, opts LinkOptions) (string, bool, error) {
+	// Check if the longURL has already been shortened (dedup). Strategies that always produce
+	// a fresh key skip this, since they never reuse keys for the same URL anyway.
+	var shortKey string
+	var err error
+	if err := checkBudget(ctx); err != nil {
+		return "", false, err
+	}
+	if !Strategy.Unique() && opts.isZero() {
+		shortKey, err = repo.FindByLongURL(ctx, longUrl)
+		if err != nil {
+			return "", false, fmt.Errorf("database lookup failed: %w", err)
+		}
 	}
 
 	//If exists, return existing shortened URL
 	if shortKey != "" {
-		return generateFullShortURL(shortKey)
+		return shortKey, false, nil
 	}
 
-	for attempt := 0; attempt < MaxRetries; attempt++ {
-		shortKey = generateShortURLKey(longUrl, attempt)
-		err = saveURLToDatabase(context.Background(), db, shortKey, longUrl)
+	// Enforce the global creation cap, only new links count against it
+	if err := CreationCap.Reserve(); err != nil {
+		return "", false, err
+	}
+
+	// New links wait for approval when it is required
+	status := StatusActive
+	if RequireApproval {
+		status = StatusPending
+	}
+
+	// Unique keys can't collide, so there is nothing to retry
+	attempts := MaxRetries
+	if Strategy.Unique() {
+		attempts = 1
+	}
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err := checkBudget(ctx); err != nil {
+			CreationCap.Release()
+			return "", false, err
+		}
+		shortKey, err = Strategy.Generate(ctx, repo, longUrl, attempt)
+		if err != nil {
+			CreationCap.Release()
+			return "", false, fmt.Errorf("failed to generate short key: %w", err)
+		}
+		err = repo.Save(ctx, shortKey, longUrl, status, opts)
 
 		if err == nil {
 			// Success, no collision and shortKey was saved to DB
-			break
+			return shortKey, true, nil
 		}
 
 		//Check of this is a retriable collision
-		if isCollisionError(err) {
+		if errors.Is(err, ErrDuplicateKey) {
 			// Hash collision occurred, retry with next salt value
 			continue
 		}
 
 		//non collision error, fail immediately
-		return "", fmt.Errorf("failed to save url: %w", err)
+		CreationCap.Release()
+		return "", false, fmt.Errorf("failed to save url: %w", err)
 	}
 
-	if err != nil {
-		// We exhausted all retries
-		return "", fmt.Errorf("failed to save url after %d attempts: %w", MaxRetries, err)
+	// We exhausted all retries
+	CreationCap.Release()
+	return "", false, fmt.Errorf("failed to save url after %d attempts: %w", attempts, err)
+}
+
+// PreviewShortKey returns the key that HandleShortURLRequest would try first for longURL,
+// along with the full short URL built from it, without touching the database.
+//
+// The key is not reserved: another URL may claim it before longURL is shortened, in which
+// case the collision retry produces a different key. If longURL was already shortened, the
+// stored key may also differ from the preview for the same reason.
+func PreviewShortKey(longURL string) (string, string, error) {
+	// Only hashed keys can be predicted from the URL
+	if _, ok := Strategy.(HashStrategy); !ok {
+		return "", "", fmt.Errorf("key preview requires the hash key strategy: %w", errors.ErrUnsupported)
 	}
 
-	return generateFullShortURL(shortKey)
+	longURL = NormalizeURL(longURL)
+	if err := ValidateLongURL(longURL); err != nil {
+		return "", "", fmt.Errorf("validation failed: %w", err)
+	}
+
+	shortKey := generateShortURLKey(longURL, 0)
+	shortURL, err := GenerateFullShortURL(shortKey)
+	if err != nil {
+		return "", "", err
+	}
+	return shortKey, shortURL, nil
 }
 
+// KeySecret is mixed into HashStrategy's hash when set (from KEY_SECRET), so keys can't be
+// predicted from the long URL and differ between deployments. Changing it only affects new
+// links: existing keys stay as they are and keep resolving, and a URL that already has a
+// link still deduplicates to it since deduplication looks links up by URL, not by key.
+var KeySecret []byte
+
 // GenerateShortURLKey creates a short, URL-safe key from a long URL.
-// It uses SHA256 to hash the long URL and then Base64 URL encoding to create a string.
-// It returns the first 7 characters of the encoded string as the key.
-// This approach is deterministic, meaning the same long URL will always produce the same short key.
+// It uses SHA256 to hash the long URL, keyed with KeySecret as an HMAC when one is set, and
+// then Base64 URL encoding to create a string.
+// It returns the first KeyLength characters of the encoded string as the key.
+// This approach is deterministic, meaning the same long URL will always produce the same
+// short key under the same secret.
 func generateShortURLKey(longUrl string, salt int) string {
 	// Hash the long URL with salt using SHA256
 	hasher := sha256.New()
+	if len(KeySecret) > 0 {
+		hasher = hmac.New(sha256.New, KeySecret)
+	}
 	hasher.Write([]byte(longUrl))
 	// Add salt to generate different hashes
 	hasher.Write([]byte(fmt.Sprintf(":%d", salt)))
@@ -133,124 +329,445 @@ func generateShortURLKey(longUrl string, salt int) string {
 	encoded := base64.URLEncoding.EncodeToString(hashBytes)
 
 	// Ensure the encoded string is long enough
-	if len(encoded) < 7 {
+	if len(encoded) < KeyLength {
 		return "encoded string too short"
 	}
 
-	// Return the first 7 characters as the key. This provides 64^7 possible keys.
-	return encoded[:7]
+	// Return the first KeyLength characters as the key. The default of 7 provides 64^7 possible keys.
+	return encoded[:KeyLength]
 }
 
-// generateFullShortURL constructs the full shortened URL by joining the base domain
+// BaseURL is the public URL short keys are appended to. Long URLs under it are rejected, since
+// they would redirect back to this service.
+var BaseURL = "http://shan747.urs/"
+
+// GenerateFullShortURL constructs the full shortened URL by joining BaseURL
 // with the provided shortKey. It uses url.JoinPath to ensure the URL is formed
 // correctly, handling any trailing or leading slashes. Returns the complete short URL
 // as a string, or an error if URL construction fails.
-func generateFullShortURL(shortKey string) (string, error) {
-	baseDomain := "http://shan747.urs/"
-
+func GenerateFullShortURL(shortKey string) (string, error) {
 	// Use url.JoinPath for robust URL construction. This correctly handles
 	// joining the domain and key, regardless of trailing slashes.
-	fullURL, err := url.JoinPath(baseDomain, shortKey)
+	fullURL, err := url.JoinPath(BaseURL, shortKey)
 	if err != nil {
 		return "", fmt.Errorf("Failed to construct full short URL: %w", err)
 	}
 	return fullURL, nil
 }
 
-// CheckDbForLongURL queries the database for an existing long URL.
-// If found, it returns the associated short key.
-// If not found, it returns an empty string and no error.
-// If a database error occurs, it returns an empty string and the error.
-func CheckDbForLongURL(ctx context.Context, db *sql.DB, longURL string) (string, error) {
-	var shortKey string
-	query := "SELECT short_key FROM urls WHERE long_url = $1"
+// HandleRedirectRequest retrieves the long URL associated with a short key and increments its click count.
+// Links that are still pending approval are treated as not found, and links past their
+// expires_at timestamp are reported as expired.
+//
+// The lookup and the increment happen in one atomic repository call (a single UPDATE ...
+// RETURNING in Postgres), which keeps click counts accurate while serving redirects.
+//
+// When RedirectCache is set, the long URL is read from the cache first and the repository only
+// counts the click. The increment is still guarded by the same status and expiry checks, so a
+// link that stopped being servable is evicted from the cache instead of being redirected.
+//
+// When Clicks is set, the lookup is a plain read and the click is handed to the buffer, so
+// the redirect returns without waiting on a write. See redirectBuffered.
+//
+// Parameters:
+//   - ctx: Context for controlling the database operation lifecycle and enabling timeouts/cancellation
+//   - repo: Storage holding the links
+//   - shortKey: The KeyLength-character short identifier to look up
+//
+// Returns:
+//   - string: The original long URL if found
+//   - error: ErrInvalidKeyLength, ErrNotFound, ErrDisabled, ErrExpired, or a wrapped error if the database query fails
+func HandleRedirectRequest(ctx context.Context, repo Repository, shortKey string) (string, error) {
+	// Validate short key format (security)
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return "", err
+	}
+	if err := checkBudget(ctx); err != nil {
+		return "", err
+	}
 
-	// QueryRowContext is used because we expect at most one result.
-	err := db.QueryRowContext(ctx, query, longURL).Scan(&shortKey)
-	if err != nil {
-		// If no rows are found, it's not an application error.
-		// It simply means the URL isn't in the database yet.
-		if err == sql.ErrNoRows {
-			return "", nil // Return empty string and nil error as requested.
+	if Clicks != nil {
+		return redirectBuffered(ctx, repo, shortKey)
+	}
+
+	if RedirectCache != nil {
+		if longURL, ok, err := RedirectCache.Get(ctx, shortKey); err == nil && ok {
+			return redirectCached(ctx, repo, shortKey, longURL)
 		}
-		// For any other error, wrap it and return for the caller to handle.
-		return "", fmt.Errorf("error querying database for long URL: %w", err)
 	}
 
-	return shortKey, nil
+	longURL, err := repo.IncrementAndGet(ctx, shortKey)
+	if err != nil {
+		return "", err
+	}
+
+	if RedirectCache != nil {
+		RedirectCache.Set(ctx, shortKey, longURL)
+	}
+
+	return longURL, nil
 }
 
-// saveURLToDatabase inserts a new URL mapping into the database.
-//
-// It stores the short key and its corresponding long URL in the urls table.
-// If a collision occurs (the short key already exists), it returns a specific
-// error indicating a unique constraint violation.
+// redirectBuffered serves a redirect when clicks are buffered. The long URL comes from
+// RedirectCache or a read-only lookup, and the click is queued on Clicks. If the buffer is
+// full the click is counted synchronously instead, so no clicks are dropped.
 //
-// Parameters:
-//   - ctx: Context for controlling the database operation lifecycle
-//   - db: Database connection pool
-//   - shortKey: The generated short identifier for the URL
-//   - longURL: The original long URL to be shortened
+// A cache hit skips the database entirely, so a link that expires while cached keeps
+// redirecting until its cache entry expires. Links with a click limit are never cached here.
+func redirectBuffered(ctx context.Context, repo Repository, shortKey string) (string, error) {
+	longURL, ok := "", false
+	if RedirectCache != nil {
+		if cached, hit, err := RedirectCache.Get(ctx, shortKey); err == nil && hit {
+			longURL, ok = cached, true
+		}
+	}
+
+	if !ok {
+		var limited bool
+		var err error
+		longURL, limited, err = repo.Get(ctx, shortKey)
+		if err != nil {
+			return "", err
+		}
+		if limited {
+			// Buffered clicks would let a limited link be used past its limit, so it is
+			// counted synchronously and never cached
+			if err := repo.Increment(ctx, shortKey); err != nil {
+				return "", err
+			}
+			return longURL, nil
+		}
+		if RedirectCache != nil {
+			RedirectCache.Set(ctx, shortKey, longURL)
+		}
+	}
+
+	if Clicks.Record(shortKey) {
+		return longURL, nil
+	}
+
+	// The buffer is full, count the click directly rather than losing it
+	if err := repo.Increment(ctx, shortKey); err != nil {
+		return "", err
+	}
+
+	return longURL, nil
+}
+
+// redirectCached counts a click for a link whose long URL came from RedirectCache. If the
+// link is no longer servable the cache entry is dropped and the reason is returned.
+func redirectCached(ctx context.Context, repo Repository, shortKey, longURL string) (string, error) {
+	if err := repo.Increment(ctx, shortKey); err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrDisabled) || errors.Is(err, ErrExpired) {
+			RedirectCache.Delete(ctx, shortKey)
+		}
+		return "", err
+	}
+
+	return longURL, nil
+}
+
+// LookupLongURL resolves a short key to its long URL without counting a click, for link
+// previews. It applies the same rules as HandleRedirectRequest, so pending links are not
+// found, disabled links return ErrDisabled, and expired links return ErrExpired.
 //
 // Returns:
-//   - nil on success
-//   - error if the short key already exists (collision) or database insert fails
-func saveURLToDatabase(ctx context.Context, db *sql.DB, shortKey string, longURL string) error {
-	query := `INSERT INTO urls (short_key, long_url) VALUES ($1, $2)`
+//   - string: The original long URL if found
+//   - error: ErrInvalidKeyLength, ErrNotFound, ErrDisabled, ErrExpired, or a wrapped error if the database query fails
+func LookupLongURL(ctx context.Context, repo Repository, shortKey string) (string, error) {
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return "", err
+	}
+	if err := checkBudget(ctx); err != nil {
+		return "", err
+	}
 
-	_, err := db.ExecContext(ctx, query, shortKey, longURL)
-	if err != nil {
-		return fmt.Errorf("database insert failed: %w", err)
+	longURL, _, err := repo.Get(ctx, shortKey)
+	return longURL, err
+}
+
+// validateShortKeyLength checks that shortKey has a length the active Strategy can produce:
+// exactly KeyLength, or longer for sequential keys. It is shared by every lookup path so
+// they all agree on what a well-formed key looks like.
+func validateShortKeyLength(shortKey string) error {
+	if len(shortKey) == KeyLength {
+		return nil
+	}
+	// Sequential keys grow past KeyLength once the sequence outgrows it
+	if _, ok := Strategy.(SequentialStrategy); ok && len(shortKey) > KeyLength && len(shortKey) <= MaxKeyLength {
+		return nil
 	}
+	return ErrInvalidKeyLength
+}
 
-	return nil
+// URLStats holds the stored information and analytics for a single short key.
+type URLStats struct {
+	ID         int64  `json:"id"`
+	ShortKey   string `json:"short_key"`
+	LongURL    string `json:"long_url"`
+	ClickCount int64  `json:"click_count"`
+	Status     string `json:"status"`
+	// Active is false once an admin has disabled the link
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ExpiredRedirect is where visitors are sent once the link has expired
+	ExpiredRedirect string `json:"expired_redirect,omitempty"`
+	// CreatedBy names the API key that created the link
+	CreatedBy string `json:"created_by,omitempty"`
+	// MaxClicks is how many redirects the link serves before it is used up
+	MaxClicks *int64 `json:"max_clicks,omitempty"`
+	// FaviconURL is the destination's favicon, only filled in when the caller asks for it
+	FaviconURL string `json:"favicon_url,omitempty"`
 }
 
-// isCollisionError checks if the provided error is a PostgreSQL unique constraint violation error (code "23505").
-// It returns true if the error indicates a collision (e.g., duplicate key), and false otherwise.
-func isCollisionError(err error) bool {
-	if err == nil {
-		return false
+// urlStatsColumns are the columns read by scanURLStats, in order.
+const urlStatsColumns = `id, short_key, long_url, click_count, status, is_active, created_at, expires_at,
+        COALESCE(expired_redirect, ''), COALESCE(created_by, ''), max_clicks`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURLStats scans a row selected with urlStatsColumns, returning ErrNotFound if there was no row.
+func scanURLStats(row rowScanner) (*URLStats, error) {
+	stats := &URLStats{}
+	err := row.Scan(&stats.ID, &stats.ShortKey, &stats.LongURL, &stats.ClickCount,
+		&stats.Status, &stats.Active, &stats.CreatedAt, &stats.ExpiresAt, &stats.ExpiredRedirect, &stats.CreatedBy,
+		&stats.MaxClicks)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("database query failed: %w", err)
 	}
-	var pqErr *pq.Error
-	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+	return stats, nil
 }
 
-// HandleRedirectRequest retrieves the long URL associated with a short key and increments its click count.
+// GetURLStats retrieves the stored mapping and click count for a short key.
 //
-// This function performs an atomic UPDATE operation that both increments the click counter
-// and returns the associated long URL in a single database query. This ensures accurate
-// analytics tracking while serving redirects.
+// Unlike HandleRedirectRequest, this is a read-only lookup and does not increment
+// the click counter.
 //
 // Parameters:
-//   - ctx: Context for controlling the database operation lifecycle and enabling timeouts/cancellation
+//   - ctx: Context for controlling the database operation lifecycle
 //   - db: Database connection pool
-//   - shortKey: The 7-character short identifier to look up
+//   - shortKey: The KeyLength-character short identifier to look up
 //
 // Returns:
-//   - string: The original long URL if found
+//   - *URLStats: The stats for the short key if found
 //   - error: If the short key is invalid format, not found in database, or database query fails
-func HandleRedirectRequest(ctx context.Context, db *sql.DB, shortKey string) (string, error) {
-	// Validate short key format (security)
-	if len(shortKey) != 7 {
-		return "", fmt.Errorf("invalid short key length")
+func GetURLStats(ctx context.Context, db Querier, shortKey string) (*URLStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + urlStatsColumns + ` FROM urls WHERE short_key = $1`
+	return scanURLStats(db.QueryRowContext(ctx, query, shortKey))
+}
+
+// GetURLStatsByID retrieves the same record as GetURLStats using the link's database ID,
+// for tooling that references links by ID rather than by key.
+//
+// Returns ErrNotFound if no link has the ID, or a wrapped error if the database query fails.
+func GetURLStatsByID(ctx context.Context, db Querier, id int64) (*URLStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + urlStatsColumns + ` FROM urls WHERE id = $1`
+	return scanURLStats(db.QueryRowContext(ctx, query, id))
+}
+
+// Page sizes for ListURLs.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// ListURLs returns one page of links, newest first, along with the total number of links so
+// callers can paginate. Links created in the same instant are ordered by ID so pages stay
+// stable. The caller is responsible for keeping limit within 1..MaxListLimit.
+//
+// Returns an empty slice (not nil) when offset is past the last link, or a wrapped error if
+// a database query fails.
+func ListURLs(ctx context.Context, db Querier, limit, offset int) ([]URLStats, int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM urls`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("database query failed: %w", err)
 	}
 
-	var longURL string
 	query := `
-        UPDATE urls
-        SET click_count = click_count + 1
-        WHERE short_key = $1
-        RETURNING long_url
+        SELECT ` + urlStatsColumns + `
+        FROM urls
+        ORDER BY created_at DESC, id DESC
+        LIMIT $1 OFFSET $2
     `
+	rows, err := db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	links := []URLStats{}
+	for rows.Next() {
+		stats, err := scanURLStats(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		links = append(links, *stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("database query failed: %w", err)
+	}
+
+	return links, total, nil
+}
+
+// ApproveShortURL activates a pending link so that it starts resolving.
+// Approving a link that is already active is a no-op.
+//
+// Returns an error if the short key is invalid format, not found in database, or the update fails.
+func ApproveShortURL(ctx context.Context, db Querier, shortKey string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return err
+	}
+
+	query := `UPDATE urls SET status = $1 WHERE short_key = $2`
 
-	err := db.QueryRowContext(ctx, query, shortKey).Scan(&longURL)
+	result, err := db.ExecContext(ctx, query, StatusActive, shortKey)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("short URL not found")
+		return fmt.Errorf("database update failed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	audit(AuditUpdate, shortKey, AuditActorAdmin)
+	return nil
+}
+
+// DisableShortURL takes a link offline without deleting it, so its analytics are kept. The
+// link stops resolving (HandleRedirectRequest returns ErrDisabled) until EnableShortURL is
+// called. Disabling a link that is already disabled is a no-op.
+//
+// Returns an error if the short key is invalid format, not found in database, or the update fails.
+func DisableShortURL(ctx context.Context, db Querier, shortKey string) error {
+	if err := setShortURLActive(ctx, db, shortKey, false); err != nil {
+		return err
+	}
+
+	// Cached redirects would otherwise keep working until they expire
+	if RedirectCache != nil {
+		RedirectCache.Delete(ctx, shortKey)
+	}
+	audit(AuditDisable, shortKey, AuditActorAdmin)
+	return nil
+}
+
+// EnableShortURL brings a link disabled with DisableShortURL back online. Enabling a link
+// that is not disabled is a no-op.
+//
+// Returns an error if the short key is invalid format, not found in database, or the update fails.
+func EnableShortURL(ctx context.Context, db Querier, shortKey string) error {
+	if err := setShortURLActive(ctx, db, shortKey, true); err != nil {
+		return err
+	}
+	audit(AuditUpdate, shortKey, AuditActorAdmin)
+	return nil
+}
+
+// UpdateLongURL points an existing link at a new destination, keeping its key and analytics.
+// The new URL is normalized and validated exactly like one being shortened, and with
+// KeepOriginalURL the link redirects to it as given.
+//
+// Returns an error wrapping ErrInvalidURL if the new URL fails validation, ErrInvalidKeyLength
+// or ErrNotFound for a bad or unknown key, or a wrapped error if the update fails.
+func UpdateLongURL(ctx context.Context, db Querier, shortKey, newLongURL string) error {
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return err
+	}
+
+	var originalURL string
+	if normalized := NormalizeURL(newLongURL); normalized != newLongURL {
+		if KeepOriginalURL {
+			if len(newLongURL) > MaxURLLength {
+				return fmt.Errorf("validation failed: %w", invalidURLf("url exceeds maximum length of %d characters", MaxURLLength))
+			}
+			originalURL = newLongURL
 		}
-		return "", fmt.Errorf("database query failed: %w", err)
+		newLongURL = normalized
+	}
+	if err := ValidateLongURL(newLongURL); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if err := checkReachable(ctx, newLongURL); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	return longURL, nil
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	// The previous destination's original goes with it
+	query := `UPDATE urls SET long_url = $1, original_url = NULLIF($3, '') WHERE short_key = $2`
+
+	result, err := db.ExecContext(ctx, query, newLongURL, shortKey, originalURL)
+	if err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	// Cached redirects would otherwise keep sending visitors to the old destination
+	if RedirectCache != nil {
+		RedirectCache.Delete(ctx, shortKey)
+	}
+	audit(AuditUpdate, shortKey, AuditActorAdmin)
+	return nil
+}
+
+// setShortURLActive sets the is_active flag of a link.
+func setShortURLActive(ctx context.Context, db Querier, shortKey string, active bool) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if err := validateShortKeyLength(shortKey); err != nil {
+		return err
+	}
+
+	query := `UPDATE urls SET is_active = $1 WHERE short_key = $2`
+
+	result, err := db.ExecContext(ctx, query, active, shortKey)
+	if err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
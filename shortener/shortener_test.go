@@ -0,0 +1,220 @@
+package shortener
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useMaxURLLength sets MaxURLLength for the rest of the test.
+func useMaxURLLength(t *testing.T, length int) {
+	t.Helper()
+	old := MaxURLLength
+	t.Cleanup(func() { MaxURLLength = old })
+	if err := SetMaxURLLength(length); err != nil {
+		t.Fatalf("SetMaxURLLength(%d): %v", length, err)
+	}
+}
+
+func TestSetMaxURLLength(t *testing.T) {
+	old := MaxURLLength
+	t.Cleanup(func() { MaxURLLength = old })
+
+	tests := []struct {
+		length int
+		ok     bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{DefaultMaxURLLength, true},
+		{8192, true},
+		{UpperMaxURLLength, true},
+		{UpperMaxURLLength + 1, false},
+	}
+	for _, tt := range tests {
+		MaxURLLength = DefaultMaxURLLength
+		err := SetMaxURLLength(tt.length)
+		if (err == nil) != tt.ok {
+			t.Errorf("SetMaxURLLength(%d) = %v, want ok %v", tt.length, err, tt.ok)
+		}
+		want := DefaultMaxURLLength
+		if tt.ok {
+			want = tt.length
+		}
+		if MaxURLLength != want {
+			t.Errorf("after SetMaxURLLength(%d), MaxURLLength = %d, want %d", tt.length, MaxURLLength, want)
+		}
+	}
+}
+
+func TestValidateLongURLLengthBoundary(t *testing.T) {
+	// urlOfLength returns a valid URL exactly n bytes long
+	urlOfLength := func(n int) string {
+		prefix := "https://example.com/"
+		return prefix + strings.Repeat("a", n-len(prefix))
+	}
+
+	for _, limit := range []int{DefaultMaxURLLength, 8192} {
+		useMaxURLLength(t, limit)
+		if err := ValidateLongURL(urlOfLength(limit)); err != nil {
+			t.Errorf("limit %d: URL at the limit rejected: %v", limit, err)
+		}
+		err := ValidateLongURL(urlOfLength(limit + 1))
+		if !errors.Is(err, ErrInvalidURL) || !strings.Contains(err.Error(), "maximum length") {
+			t.Errorf("limit %d: URL one byte over the limit = %v, want a length error", limit, err)
+		}
+	}
+}
+
+// memoryBackedDB returns a fake database that applies the admin status and is_active updates
+// made through a Querier to repo, so admin functions can be tested against the links repo
+// serves.
+func memoryBackedDB(t *testing.T, repo *MemoryRepository) Querier {
+	t.Helper()
+	return newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		var err error
+		switch {
+		case strings.Contains(query, "SET status"):
+			err = repo.SetStatus(args[1].Value.(string), args[0].Value.(string))
+		case strings.Contains(query, "SET is_active"):
+			err = repo.SetActive(args[1].Value.(string), args[0].Value.(bool))
+		default:
+			return fakeResult{}, errors.New("unexpected statement: " + query)
+		}
+		if err != nil {
+			return fakeResult{}, nil
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	})
+}
+
+func TestPendingLinkResolvesAfterApproval(t *testing.T) {
+	old := RequireApproval
+	RequireApproval = true
+	t.Cleanup(func() { RequireApproval = old })
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	db := memoryBackedDB(t, repo)
+
+	result, err := HandleShortURLRequest(ctx, "https://example.com/launch", repo, LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if _, err := HandleRedirectRequest(ctx, repo, result.ShortKey); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("redirect before approval = %v, want ErrNotFound", err)
+	}
+
+	if err := ApproveShortURL(ctx, db, result.ShortKey); err != nil {
+		t.Fatalf("ApproveShortURL: %v", err)
+	}
+	longURL, err := HandleRedirectRequest(ctx, repo, result.ShortKey)
+	if err != nil || longURL != "https://example.com/launch" {
+		t.Errorf("redirect after approval = %q, %v", longURL, err)
+	}
+
+	if err := ApproveShortURL(ctx, db, "AAAAAAA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ApproveShortURL(unknown) = %v, want ErrNotFound", err)
+	}
+	if err := ApproveShortURL(ctx, db, "short"); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("ApproveShortURL(short) = %v, want ErrInvalidKeyLength", err)
+	}
+}
+
+func TestDisabledLinkStopsRedirecting(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	db := memoryBackedDB(t, repo)
+	key := "abcdefg"
+	repo.Save(ctx, key, "https://example.com/", StatusActive, LinkOptions{})
+
+	if err := DisableShortURL(ctx, db, key); err != nil {
+		t.Fatalf("DisableShortURL: %v", err)
+	}
+	if _, err := HandleRedirectRequest(ctx, repo, key); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("redirect while disabled = %v, want ErrDisabled", err)
+	}
+	if err := DisableShortURL(ctx, db, key); err != nil {
+		t.Errorf("disabling again = %v, want a no-op", err)
+	}
+
+	if err := EnableShortURL(ctx, db, key); err != nil {
+		t.Fatalf("EnableShortURL: %v", err)
+	}
+	if longURL, err := HandleRedirectRequest(ctx, repo, key); err != nil || longURL != "https://example.com/" {
+		t.Errorf("redirect after enabling = %q, %v", longURL, err)
+	}
+	// Clicks from before the link was disabled are kept
+	if clicks, _ := repo.ClickCount(key); clicks != 2 {
+		t.Errorf("click count = %d, want 2", clicks)
+	}
+
+	if err := DisableShortURL(ctx, db, "AAAAAAA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DisableShortURL(unknown) = %v, want ErrNotFound", err)
+	}
+	if err := EnableShortURL(ctx, db, "short"); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("EnableShortURL(short) = %v, want ErrInvalidKeyLength", err)
+	}
+}
+
+func TestPreviewShortKeyIsDeterministic(t *testing.T) {
+	first, firstURL, err := PreviewShortKey("https://example.com/docs")
+	if err != nil {
+		t.Fatalf("PreviewShortKey: %v", err)
+	}
+	for _, variant := range []string{"https://example.com/docs", "HTTPS://Example.com:443/docs"} {
+		key, shortURL, err := PreviewShortKey(variant)
+		if err != nil || key != first || shortURL != firstURL {
+			t.Errorf("PreviewShortKey(%q) = %q, %q, %v, want %q, %q", variant, key, shortURL, err, first, firstURL)
+		}
+	}
+
+	result, err := HandleShortURLRequest(context.Background(), "https://example.com/docs", NewMemoryRepository(), LinkOptions{})
+	if err != nil {
+		t.Fatalf("HandleShortURLRequest: %v", err)
+	}
+	if result.ShortKey != first {
+		t.Errorf("shortened to %q, want the previewed key %q", result.ShortKey, first)
+	}
+
+	if _, _, err := PreviewShortKey("ftp://example.com/"); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("PreviewShortKey(invalid) = %v, want ErrInvalidURL", err)
+	}
+	useStrategy(t, SequentialStrategy{})
+	if _, _, err := PreviewShortKey("https://example.com/docs"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("PreviewShortKey with sequential keys = %v, want errors.ErrUnsupported", err)
+	}
+}
+
+func TestGetURLStatsByID(t *testing.T) {
+	created := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		if !strings.Contains(query, "WHERE id = $1") {
+			return fakeResult{}, errors.New("unexpected statement: " + query)
+		}
+		result := fakeResult{columns: strings.Split("id short_key long_url click_count status is_active "+
+			"created_at expires_at expired_redirect created_by max_clicks", " ")}
+		if args[0].Value == int64(7) {
+			result.rows = [][]driver.Value{{int64(7), "abcdefg", "https://example.com/", int64(3), StatusActive, true,
+				created, nil, "", "", nil}}
+		}
+		return result, nil
+	})
+	ctx := context.Background()
+
+	stats, err := GetURLStatsByID(ctx, db, 7)
+	if err != nil {
+		t.Fatalf("GetURLStatsByID(7): %v", err)
+	}
+	if stats.ID != 7 || stats.ShortKey != "abcdefg" || stats.LongURL != "https://example.com/" || stats.ClickCount != 3 ||
+		!stats.CreatedAt.Equal(created) || stats.ExpiresAt != nil || stats.MaxClicks != nil {
+		t.Errorf("GetURLStatsByID(7) = %+v", stats)
+	}
+
+	if _, err := GetURLStatsByID(ctx, db, 8); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetURLStatsByID(8) = %v, want ErrNotFound", err)
+	}
+}
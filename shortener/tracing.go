@@ -0,0 +1,41 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans around database operations. It uses the global TracerProvider,
+// so spans are only recorded once the service has configured an exporter.
+var tracer = otel.Tracer("github.com/shantanu747/URL-Shortener/shortener")
+
+// startSpan starts a span for the database operation name on the urls table, as a child of
+// the span in ctx.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.collection.name", "urls")))
+}
+
+// endSpan ends span, recording err on it first. Errors that describe the link rather than a
+// failed operation, such as a missing link or a key collision, are not recorded.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !isLinkOutcome(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// isLinkOutcome reports whether err is an expected answer about a link.
+func isLinkOutcome(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrDisabled) || errors.Is(err, ErrExpired) ||
+		errors.Is(err, ErrDuplicateKey)
+}
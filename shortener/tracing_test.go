@@ -0,0 +1,87 @@
+package shortener
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter     = tracetest.NewInMemoryExporter()
+	spanExporterOnce sync.Once
+)
+
+// recordSpans routes the package's spans to spanExporter, emptied for the test. The global
+// provider only delegates once, so every test shares the same exporter.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	spanExporterOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+func TestPostgresRepositorySpans(t *testing.T) {
+	exporter := recordSpans(t)
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+		switch {
+		case strings.Contains(query, "SELECT short_key FROM urls"):
+			return fakeResult{columns: []string{"short_key"}}, nil
+		case strings.Contains(query, "UPDATE urls"):
+			return fakeResult{}, errors.New("connection reset")
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	})
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	if _, err := repo.FindByLongURL(ctx, "https://example.com/"); err != nil {
+		t.Fatalf("FindByLongURL: %v", err)
+	}
+	if err := repo.Save(ctx, "abcdefg", "https://example.com/", StatusActive, LinkOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := repo.IncrementAndGet(ctx, "abcdefg"); err == nil {
+		t.Fatal("IncrementAndGet succeeded, want the database error")
+	}
+
+	spans := exporter.GetSpans()
+	want := []struct {
+		name   string
+		status codes.Code
+	}{
+		{"urls.find_by_long_url", codes.Unset},
+		{"urls.insert", codes.Unset},
+		{"urls.increment_and_get", codes.Error},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("recorded %d spans, want %d", len(spans), len(want))
+	}
+	for i, w := range want {
+		if spans[i].Name != w.name || spans[i].Status.Code != w.status {
+			t.Errorf("span %d = %s (%v), want %s (%v)", i, spans[i].Name, spans[i].Status.Code, w.name, w.status)
+		}
+	}
+}
+
+func TestEndSpanSkipsLinkOutcomes(t *testing.T) {
+	exporter := recordSpans(t)
+	for _, err := range []error{ErrNotFound, ErrDisabled, &ExpiredError{}, ErrDuplicateKey} {
+		_, span := startSpan(context.Background(), "urls.get")
+		endSpan(span, err)
+	}
+	for _, span := range exporter.GetSpans() {
+		if span.Status.Code == codes.Error || len(span.Events) > 0 {
+			t.Errorf("span recorded %v as an error", span.Status.Description)
+		}
+	}
+}
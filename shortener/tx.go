@@ -0,0 +1,46 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TxBeginner starts transactions. *sql.DB and *sql.Conn satisfy it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Transactor is implemented by repositories that can run several operations atomically.
+type Transactor interface {
+	// InTx runs fn with a repository whose operations all happen in one transaction. The
+	// transaction commits if fn returns nil and rolls back otherwise.
+	InTx(ctx context.Context, fn func(repo Repository) error) error
+}
+
+// WithTx runs fn inside a transaction on db. The transaction is committed if fn returns nil
+// and rolled back if it returns an error or panics.
+func WithTx(ctx context.Context, db TxBeginner, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolling back after a successful commit is a no-op
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// runInTx runs fn in a transaction when repo supports them, and directly otherwise.
+func runInTx(ctx context.Context, repo Repository, fn func(repo Repository) error) error {
+	if t, ok := repo.(Transactor); ok {
+		return t.InTx(ctx, fn)
+	}
+	return fn(repo)
+}
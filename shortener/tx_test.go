@@ -0,0 +1,89 @@
+package shortener
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWithTx(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name    string
+		fn      func(tx *sql.Tx) error
+		wantEnd string
+	}{
+		{"commits on success", func(tx *sql.Tx) error { return nil }, "COMMIT"},
+		{"rolls back on error", func(tx *sql.Tx) error { return errFailed }, "ROLLBACK"},
+		{"rolls back on panic", func(tx *sql.Tx) error { panic("boom") }, "ROLLBACK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ends []string
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) (fakeResult, error) {
+				ends = append(ends, query)
+				return fakeResult{}, nil
+			})
+
+			func() {
+				defer func() { recover() }()
+				WithTx(context.Background(), db, tt.fn)
+			}()
+			if len(ends) != 1 || ends[0] != tt.wantEnd {
+				t.Errorf("transaction ended with %v, want only %s", ends, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// serialRepository runs transactions one at a time, like the advisory lock
+// PostgresRepository takes on the long URL.
+type serialRepository struct {
+	*MemoryRepository
+	mu sync.Mutex
+}
+
+func (r *serialRepository) InTx(ctx context.Context, fn func(repo Repository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fn(r.MemoryRepository)
+}
+
+func TestConcurrentShortenCreatesOneLink(t *testing.T) {
+	useStrategy(t, HashStrategy{})
+	repo := &serialRepository{MemoryRepository: NewMemoryRepository()}
+
+	const requests = 20
+	keys := make([]string, requests)
+	created := make([]bool, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := HandleShortURLRequest(context.Background(), "https://example.com/page", repo, LinkOptions{})
+			if err != nil {
+				t.Errorf("HandleShortURLRequest: %v", err)
+				return
+			}
+			keys[i], created[i] = result.ShortKey, result.Created
+		}()
+	}
+	wg.Wait()
+
+	var creates int
+	for i := range requests {
+		if created[i] {
+			creates++
+		}
+		if keys[i] != keys[0] {
+			t.Errorf("request %d got key %q, want every request to share %q", i, keys[i], keys[0])
+		}
+	}
+	if creates != 1 {
+		t.Errorf("%d requests created a link, want exactly 1", creates)
+	}
+}
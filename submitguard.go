@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+// maxSweepInterval bounds how often the in-memory request caches scan for expired entries.
+const maxSweepInterval = time.Minute
+
+// errSubmitAborted is what waiting submissions get when the first one panicked.
+var errSubmitAborted = errors.New("duplicate submission aborted")
+
+// submitGuard collapses identical shorten submissions from the same client within a short
+// window into a single link. It protects against double-click and flaky-retry bugs when
+// deduplication is off (e.g. with the sequential key strategy), without the bookkeeping of
+// full idempotency keys. State is in memory and per instance.
+type submitGuard struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*submitEntry
+	// nextSweep is when expired entries are next removed
+	nextSweep time.Time
+}
+
+// submitEntry is one remembered submission. done is closed once the first request for the
+// key finishes, after which result, err, and expires are safe to read.
+type submitEntry struct {
+	done    chan struct{}
+	result  shortener.ShortenResult
+	err     error
+	expires time.Time
+}
+
+func newSubmitGuard(window time.Duration) *submitGuard {
+	return &submitGuard{
+		window:  window,
+		entries: make(map[string]*submitEntry),
+	}
+}
+
+// submitKey identifies a submission by who submitted it (see Store.requesterScope) and the
+// URL they sent, normalized so trivially different spellings of one URL count as the same.
+func submitKey(owner, longURL string) string {
+	return owner + " " + shortener.NormalizeURL(longURL)
+}
+
+// do runs create for the first submission of key and returns its result to every identical
+// submission that arrives while it is running or within the window after it succeeded.
+// Only the first submission reports created, since the others reuse its link. Failed
+// submissions, including ones that panic, are forgotten so the client can retry.
+func (g *submitGuard) do(key string, create func() (shortener.ShortenResult, error)) (shortener.ShortenResult, error) {
+	g.mu.Lock()
+	now := time.Now()
+	if now.After(g.nextSweep) {
+		for k, e := range g.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(g.entries, k)
+			}
+		}
+		g.nextSweep = now.Add(min(g.window, maxSweepInterval))
+	}
+	if e, ok := g.entries[key]; ok && (e.expires.IsZero() || !now.After(e.expires)) {
+		g.mu.Unlock()
+		<-e.done
+		result := e.result
+		result.Created = false
+		return result, e.err
+	}
+	// Stays set if create panics, so waiters fail instead of blocking forever
+	e := &submitEntry{done: make(chan struct{}), err: errSubmitAborted}
+	g.entries[key] = e
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		e.expires = time.Now().Add(g.window)
+		if e.err != nil && g.entries[key] == e {
+			delete(g.entries, key)
+		}
+		g.mu.Unlock()
+		close(e.done)
+	}()
+
+	e.result, e.err = create()
+	return e.result, e.err
+}
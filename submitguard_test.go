@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shantanu747/URL-Shortener/shortener"
+)
+
+func TestShortenCollapsesRapidDuplicateSubmits(t *testing.T) {
+	// Sequential keys never deduplicate, so without the guard each submit is a new link
+	useStrategy(t, shortener.SequentialStrategy{})
+	s, _ := newTestStore()
+	s.submitGuard = newSubmitGuard(time.Minute)
+
+	body := `{"long_url": "https://example.com/page"}`
+	w1, first := postShorten(t, s, body, nil)
+	w2, second := postShorten(t, s, body, nil)
+	if first.ShortKey == "" || second.ShortKey != first.ShortKey {
+		t.Fatalf("short keys = %q, %q, want the same link twice", first.ShortKey, second.ShortKey)
+	}
+	if w1.Code != 201 || w2.Code != 200 {
+		t.Errorf("statuses = %d, %d, want 201 then 200", w1.Code, w2.Code)
+	}
+
+	// Another spelling of the same URL is the same submission
+	_, third := postShorten(t, s, `{"long_url": "HTTPS://Example.com:443/page"}`, nil)
+	if third.ShortKey != first.ShortKey {
+		t.Errorf("equivalent URL got %q, want %q", third.ShortKey, first.ShortKey)
+	}
+
+	// Without the guard the same submit creates another link
+	s.submitGuard = nil
+	if _, fourth := postShorten(t, s, body, nil); fourth.ShortKey == first.ShortKey {
+		t.Errorf("without the guard a resubmit reused %q", first.ShortKey)
+	}
+}
+
+func TestSubmitGuardConcurrentSubmissions(t *testing.T) {
+	g := newSubmitGuard(time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	create := func() (shortener.ShortenResult, error) {
+		calls.Add(1)
+		<-release
+		return shortener.ShortenResult{ShortKey: "abc1234", Created: true}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]shortener.ShortenResult, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.do("client https://example.com/", create)
+		}()
+	}
+	// Let every submission reach the guard before the first one finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("create ran %d times, want once", calls.Load())
+	}
+	created := 0
+	for _, result := range results {
+		if result.ShortKey != "abc1234" {
+			t.Errorf("result %+v, want key abc1234", result)
+		}
+		if result.Created {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d results reported created, want 1", created)
+	}
+}
+
+func TestSubmitGuardForgetsFailures(t *testing.T) {
+	g := newSubmitGuard(time.Minute)
+	failure := errors.New("database down")
+	if _, err := g.do("k", func() (shortener.ShortenResult, error) { return shortener.ShortenResult{}, failure }); err != failure {
+		t.Fatalf("first do = %v, want the failure", err)
+	}
+	result, err := g.do("k", func() (shortener.ShortenResult, error) {
+		return shortener.ShortenResult{ShortKey: "abc1234", Created: true}, nil
+	})
+	if err != nil || !result.Created {
+		t.Errorf("retry after a failure = %+v, %v, want a fresh attempt", result, err)
+	}
+}
+
+func TestSubmitGuardExpires(t *testing.T) {
+	g := newSubmitGuard(10 * time.Millisecond)
+	create := func() (shortener.ShortenResult, error) {
+		return shortener.ShortenResult{ShortKey: "abc1234", Created: true}, nil
+	}
+	g.do("k", create)
+	time.Sleep(20 * time.Millisecond)
+	if result, _ := g.do("k", create); !result.Created {
+		t.Error("submission after the window was collapsed into the earlier one")
+	}
+}
+
+func TestSubmitGuardPanicDoesNotBlockLaterSubmissions(t *testing.T) {
+	g := newSubmitGuard(time.Minute)
+	started := make(chan struct{})
+	waiterDone := make(chan error)
+
+	go func() {
+		defer func() { recover() }()
+		g.do("k", func() (shortener.ShortenResult, error) {
+			close(started)
+			// Give the second submission time to start waiting on this one
+			time.Sleep(50 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+	go func() {
+		_, err := g.do("k", func() (shortener.ShortenResult, error) {
+			return shortener.ShortenResult{}, errors.New("waiter should not run create")
+		})
+		waiterDone <- err
+	}()
+
+	select {
+	case err := <-waiterDone:
+		if !errors.Is(err, errSubmitAborted) {
+			t.Errorf("waiter got %v, want errSubmitAborted", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("submission waiting on a panicked one never returned")
+	}
+
+	result, err := g.do("k", func() (shortener.ShortenResult, error) {
+		return shortener.ShortenResult{ShortKey: "abc1234", Created: true}, nil
+	})
+	if err != nil || !result.Created {
+		t.Errorf("submission after the panic = %+v, %v, want a fresh attempt", result, err)
+	}
+}
+
+func TestSubmitKeyScopesByOwnerAndNormalizesURL(t *testing.T) {
+	if submitKey("key:alice", "HTTPS://Example.com/a") != submitKey("key:alice", "https://example.com/a") {
+		t.Error("equivalent URLs got different submit keys")
+	}
+	if submitKey("key:alice", "https://example.com/a") == submitKey("key:bob", "https://example.com/a") {
+		t.Error("different owners got the same submit key")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter     = tracetest.NewInMemoryExporter()
+	spanExporterOnce sync.Once
+)
+
+// recordSpans routes spans to spanExporter, emptied for the test. The global provider only
+// delegates once, so every test shares the same exporter.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	spanExporterOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+func TestWithTracingContinuesIncomingTrace(t *testing.T) {
+	exporter := recordSpans(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	handler := withTracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("span trace ID = %s, want the incoming %s", got, traceID)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("span parent = %s, want the incoming parent span", got)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status = %v, want an error for a 503", span.Status.Code)
+	}
+}
+
+func TestRequestLogsCarryTraceID(t *testing.T) {
+	exporter := recordSpans(t)
+	logs := captureLogs(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	handler := withTracing(withRequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for _, tt := range []struct {
+		name, traceparent string
+		continued         bool
+	}{
+		{"traced", "00-" + traceID + "-00f067aa0ba902b7-01", true},
+		{"malformed parent", "00-" + traceID + "-0000000000000000-01", false},
+		{"untraced", "", false},
+	} {
+		logs.Reset()
+		exporter.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/health", nil)
+		if tt.traceparent != "" {
+			r.Header.Set("traceparent", tt.traceparent)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		var line struct {
+			TraceID string `json:"trace_id"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+			t.Fatalf("%s: decoding log line %q: %v", tt.name, logs.String(), err)
+		}
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("%s: recorded %d spans, want 1", tt.name, len(spans))
+		}
+		if want := spans[0].SpanContext.TraceID().String(); line.TraceID != want {
+			t.Errorf("%s: trace_id = %q, want the request span's %q", tt.name, line.TraceID, want)
+		}
+		if continued := line.TraceID == traceID; continued != tt.continued {
+			t.Errorf("%s: continued incoming trace = %v, want %v", tt.name, continued, tt.continued)
+		}
+	}
+}